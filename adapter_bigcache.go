@@ -1,37 +1,72 @@
 package cacheman
 
 import (
+	"encoding/binary"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/allegro/bigcache"
 )
 
+const (
+	// expiryLength is length of expiry timestamp prefixed to each BigCache entry
+	expiryLength = 8
+	// keyLengthLength is length of key length following expiry timestamp
+	keyLengthLength = 2
+	// minTagSweep is size of tag index below which it is not swept
+	minTagSweep = 1024
+)
+
 type BigCacheClient struct {
 	client *bigcache.BigCache
+	ttl    time.Duration
+
+	tagLock sync.Mutex
+	// tags maps tag to expiry of each key tagged with it
+	tags map[string]map[string]int64
+	// tagged is number of tagged keys in tags, and swept is that number after last sweep
+	tagged int
+	swept  int
 }
 
-// NewBigCache creates big cache client
+// NewBigCache creates big cache client.
+// BigCache evicts entries by a single life window, so it is set to the longest time an entry is kept,
+// including stale windows and statuses, and shorter TTL is checked against expiry stored with each entry.
+// Longer TTL, e.g. max-age of response with RespectHeadersPolicy, is cut short by the life window.
 func NewBigCache(config *Config) (*BigCacheClient, error) {
-	ttl, e := time.ParseDuration(config.TTL)
-	if e != nil {
-		ttl, _ = time.ParseDuration(defaultTTL)
-	}
-	client, e := bigcache.NewBigCache(bigcache.DefaultConfig(ttl))
+	ttl := parseTTL(config.TTL)
+	lifeWindow := config.maxStoreTTL()
+	client, e := bigcache.NewBigCache(bigcache.DefaultConfig(lifeWindow))
 	if e != nil {
 		return nil, e
 	}
 	return &BigCacheClient{
 		client: client,
+		ttl:    ttl,
+		tags:   map[string]map[string]int64{},
 	}, nil
 }
 
 func (c *BigCacheClient) Get(key string) ([]byte, error) {
-	return c.client.Get(key)
+	entry, e := c.client.Get(key)
+	if e != nil {
+		return nil, e
+	}
+	expiry, _, value, ok := unwrapBigCacheEntry(entry)
+	if !ok || time.Now().UnixNano() > expiry {
+		return nil, bigcache.ErrEntryNotFound
+	}
+	return value, nil
 }
 
 func (c *BigCacheClient) Set(key string, value []byte) error {
-	return c.client.Set(key, value)
+	return c.SetWithTTL(key, value, c.ttl)
+}
+
+func (c *BigCacheClient) SetWithTTL(key string, value []byte, ttl time.Duration) error {
+	return c.client.Set(key, wrapBigCacheEntry(time.Now().Add(ttl).UnixNano(), key, value))
 }
 
 func (c *BigCacheClient) Delete(key string) error {
@@ -39,9 +74,146 @@ func (c *BigCacheClient) Delete(key string) error {
 }
 
 func (c *BigCacheClient) Reset() error {
+	c.tagLock.Lock()
+	c.tags = map[string]map[string]int64{}
+	c.tagged, c.swept = 0, 0
+	c.tagLock.Unlock()
 	return c.client.Reset()
 }
 
+func (c *BigCacheClient) DeletePrefix(prefix string) error {
+	return c.deleteMatching(func(key string) bool {
+		return strings.HasPrefix(key, prefix)
+	})
+}
+
+func (c *BigCacheClient) DeletePattern(pattern string) error {
+	matcher := compileGlob(pattern)
+	return c.deleteMatching(matcher.MatchString)
+}
+
+// deleteMatching deletes every entry whose key matches
+func (c *BigCacheClient) deleteMatching(match func(key string) bool) error {
+	keys := []string{}
+	iterator := c.client.Iterator()
+	for iterator.SetNext() {
+		entry, e := iterator.Value()
+		if e != nil {
+			continue
+		}
+		// Key is read from our own entry header, EntryInfo.Key of this BigCache version is unreliable
+		_, key, _, ok := unwrapBigCacheEntry(entry.Value())
+		if ok && match(key) {
+			keys = append(keys, key)
+		}
+	}
+	for _, key := range keys {
+		c.client.Delete(key)
+	}
+	return nil
+}
+
+// AddTags indexes key by tags in memory with its expiry.
+// Index is swept of expired, deleted and evicted keys whenever it doubles in size since last sweep.
+func (c *BigCacheClient) AddTags(key string, tags []string, ttl time.Duration) error {
+	c.tagLock.Lock()
+	defer c.tagLock.Unlock()
+	expiry := time.Now().Add(ttl).UnixNano()
+	for _, tag := range tags {
+		if c.tags[tag] == nil {
+			c.tags[tag] = map[string]int64{}
+		}
+		existing, ok := c.tags[tag][key]
+		if !ok {
+			c.tagged++
+		}
+		if expiry > existing {
+			c.tags[tag][key] = expiry
+		}
+	}
+	if c.tagged >= minTagSweep && c.tagged >= 2*c.swept {
+		c.sweepTags()
+	}
+	return nil
+}
+
+// sweepTags removes keys which are expired or no longer in BigCache from tag index, tagLock must be held
+func (c *BigCacheClient) sweepTags() {
+	now := time.Now().UnixNano()
+	for tag, keys := range c.tags {
+		for key, expiry := range keys {
+			if now > expiry {
+				delete(keys, key)
+			} else if _, e := c.client.Get(key); e != nil {
+				delete(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+	c.tagged = 0
+	for _, keys := range c.tags {
+		c.tagged += len(keys)
+	}
+	c.swept = c.tagged
+}
+
+func (c *BigCacheClient) DeleteTags(tags ...string) error {
+	c.tagLock.Lock()
+	keys := []string{}
+	for _, tag := range tags {
+		for key := range c.tags[tag] {
+			keys = append(keys, key)
+		}
+		c.tagged -= len(c.tags[tag])
+		delete(c.tags, tag)
+	}
+	c.tagLock.Unlock()
+	for _, key := range keys {
+		c.client.Delete(key)
+	}
+	return nil
+}
+
 func (c *BigCacheClient) Type() string {
 	return fmt.Sprintf("%T", c)
 }
+
+// wrapBigCacheEntry prefixes value with expiry timestamp and key
+func wrapBigCacheEntry(expiry int64, key string, value []byte) []byte {
+	headerLength := expiryLength + keyLengthLength + len(key)
+	entry := make([]byte, headerLength+len(value))
+	binary.BigEndian.PutUint64(entry, uint64(expiry))
+	binary.BigEndian.PutUint16(entry[expiryLength:], uint16(len(key)))
+	copy(entry[expiryLength+keyLengthLength:], key)
+	copy(entry[headerLength:], value)
+	return entry
+}
+
+// unwrapBigCacheEntry splits entry into expiry timestamp, key and value
+func unwrapBigCacheEntry(entry []byte) (int64, string, []byte, bool) {
+	if len(entry) < expiryLength+keyLengthLength {
+		return 0, "", nil, false
+	}
+	expiry := int64(binary.BigEndian.Uint64(entry))
+	headerLength := expiryLength + keyLengthLength + int(binary.BigEndian.Uint16(entry[expiryLength:]))
+	if len(entry) < headerLength {
+		return 0, "", nil, false
+	}
+	return expiry, string(entry[expiryLength+keyLengthLength : headerLength]), entry[headerLength:], true
+}
+
+// Stats reports entries, capacity in bytes, hits, misses and collisions of BigCache
+func (c *BigCacheClient) Stats() (map[string]interface{}, error) {
+	stats := c.client.Stats()
+	return map[string]interface{}{
+		"entries":       c.client.Len(),
+		"capacityBytes": c.client.Capacity(),
+		"hits":          stats.Hits,
+		"misses":        stats.Misses,
+		"deleteHits":    stats.DelHits,
+		"deleteMisses":  stats.DelMisses,
+		"collisions":    stats.Collisions,
+	}, nil
+}
@@ -1,26 +1,36 @@
 package cacheman
 
 import (
+	"bufio"
 	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/bradfitz/gomemcache/memcache"
 )
 
+// maxMemcachedRelativeTTL is the longest expiration memcached reads as seconds from now
+const maxMemcachedRelativeTTL = 30 * 24 * time.Hour
+
+// maxIndexUpdates is number of attempts to update a tag index which is concurrently updated by others
+const maxIndexUpdates = 10
+
 type MemcachedClient struct {
 	client *memcache.Client
+	server string
 	ttl    time.Duration
 }
 
 // NewMemcached creates big cache client
 func NewMemcached(config *Config) (*MemcachedClient, error) {
-	ttl, e := time.ParseDuration(config.TTL)
-	if e != nil {
-		ttl, _ = time.ParseDuration(defaultTTL)
-	}
+	ttl := parseTTL(config.TTL)
 	client := memcache.New(config.Server)
 	return &MemcachedClient{
 		client: client,
+		server: config.Server,
 		ttl:    ttl,
 	}, nil
 }
@@ -34,10 +44,14 @@ func (c *MemcachedClient) Get(key string) ([]byte, error) {
 }
 
 func (c *MemcachedClient) Set(key string, value []byte) error {
+	return c.SetWithTTL(key, value, c.ttl)
+}
+
+func (c *MemcachedClient) SetWithTTL(key string, value []byte, ttl time.Duration) error {
 	return c.client.Set(&memcache.Item{
 		Key:        key,
 		Value:      value,
-		Expiration: int32(c.ttl.Seconds()),
+		Expiration: memcachedExpiration(ttl),
 	})
 }
 
@@ -45,10 +59,195 @@ func (c *MemcachedClient) Delete(key string) error {
 	return c.client.Delete(key)
 }
 
+// Reset deletes every entry in memcached, also entries of other namespaces as memcached cannot list its keys
 func (c *MemcachedClient) Reset() error {
 	return c.client.DeleteAll()
 }
 
+// AddTags adds key into index of each tag. Index lives as long as its longest-lived key.
+func (c *MemcachedClient) AddTags(key string, tags []string, ttl time.Duration) error {
+	expiresAt := time.Now().Add(ttl).Unix()
+	for _, tag := range tags {
+		if e := c.addTag(tag, key, expiresAt); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+func (c *MemcachedClient) DeleteTags(tags ...string) error {
+	for _, tag := range tags {
+		item, e := c.client.Get(tag)
+		if e == memcache.ErrCacheMiss {
+			continue
+		}
+		if e != nil {
+			return e
+		}
+		_, keys := parseTagIndex(item.Value)
+		for _, key := range keys {
+			c.client.Delete(key)
+		}
+		c.client.Delete(tag)
+	}
+	return nil
+}
+
 func (c *MemcachedClient) Type() string {
 	return fmt.Sprintf("%T", c)
 }
+
+// addTag adds key into tag index with compare-and-swap, so concurrent updates are not lost.
+// Index keeps each key once, and keys which are no longer in memcached are dropped when key is new.
+func (c *MemcachedClient) addTag(tag, key string, expiresAt int64) error {
+	for attempt := 0; attempt < maxIndexUpdates; attempt++ {
+		item, e := c.client.Get(tag)
+		if e == memcache.ErrCacheMiss {
+			e = c.client.Add(newTagIndex(tag, expiresAt, []string{key}))
+			if e == memcache.ErrNotStored {
+				continue
+			}
+			return e
+		}
+		if e != nil {
+			return e
+		}
+		indexExpiresAt, keys := parseTagIndex(item.Value)
+		if expiresAt < indexExpiresAt {
+			expiresAt = indexExpiresAt
+		}
+		if !containsString(keys, key) {
+			keys = append(c.existingKeys(keys), key)
+		} else if expiresAt == indexExpiresAt {
+			return nil
+		}
+		// Item keeps its CAS ID from Get, so it is swapped only if nobody changed it since
+		index := newTagIndex(tag, expiresAt, keys)
+		item.Value = index.Value
+		item.Expiration = index.Expiration
+		e = c.client.CompareAndSwap(item)
+		if e == memcache.ErrCASConflict || e == memcache.ErrNotStored {
+			continue
+		}
+		return e
+	}
+	return fmt.Errorf("tag index %s is updated concurrently", tag)
+}
+
+// existingKeys returns keys which are still in memcached
+func (c *MemcachedClient) existingKeys(keys []string) []string {
+	if len(keys) == 0 {
+		return keys
+	}
+	items, e := c.client.GetMulti(keys)
+	if e != nil {
+		return keys
+	}
+	existing := []string{}
+	for _, key := range keys {
+		if _, ok := items[key]; ok {
+			existing = append(existing, key)
+		}
+	}
+	return existing
+}
+
+// newTagIndex creates item of tag index: unix time it expires at, then one key per line
+func newTagIndex(tag string, expiresAt int64, keys []string) *memcache.Item {
+	return &memcache.Item{
+		Key:        tag,
+		Value:      []byte(strconv.FormatInt(expiresAt, 10) + "\n" + strings.Join(keys, "\n")),
+		Expiration: memcachedExpiration(time.Until(time.Unix(expiresAt, 0))),
+	}
+}
+
+// parseTagIndex returns unix time tag index expires at, and its unique keys
+func parseTagIndex(value []byte) (int64, []string) {
+	lines := strings.Split(string(value), "\n")
+	expiresAt, _ := strconv.ParseInt(lines[0], 10, 64)
+	seen := map[string]bool{}
+	keys := []string{}
+	for _, key := range lines[1:] {
+		if key != "" && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return expiresAt, keys
+}
+
+// memcachedExpiration converts TTL into memcached expiration in seconds, sub-second TTL is rounded up.
+// Memcached reads expiration over 30 days as unix time, so longer TTL is converted into expiry time.
+func memcachedExpiration(ttl time.Duration) int32 {
+	if ttl > maxMemcachedRelativeTTL {
+		return int32(time.Now().Add(ttl).Unix())
+	}
+	expiration := int32((ttl + time.Second - 1) / time.Second)
+	if expiration < 1 {
+		expiration = 1
+	}
+	return expiration
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Stats reports entries, memory use, hits, misses and evictions of memcached server
+func (c *MemcachedClient) Stats() (map[string]interface{}, error) {
+	raw, e := memcachedStats(c.server, c.client.Timeout)
+	if e != nil {
+		return nil, e
+	}
+	return backendStats(raw, map[string]string{
+		"curr_items":     "entries",
+		"bytes":          "memoryBytes",
+		"limit_maxbytes": "limitBytes",
+		"get_hits":       "hits",
+		"get_misses":     "misses",
+		"evictions":      "evictions",
+	}), nil
+}
+
+// memcachedStats reads reply of stats command from memcached server, gomemcache does not support it
+func memcachedStats(server string, timeout time.Duration) (map[string]string, error) {
+	if timeout <= 0 {
+		timeout = memcache.DefaultTimeout
+	}
+	network := "tcp"
+	if strings.Contains(server, "/") {
+		network = "unix"
+	}
+	conn, e := net.DialTimeout(network, server, timeout)
+	if e != nil {
+		return nil, e
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	if _, e := fmt.Fprint(conn, "stats\r\n"); e != nil {
+		return nil, e
+	}
+	stats := map[string]string{}
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "END" {
+			return stats, nil
+		}
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) == 3 && fields[0] == "STAT" {
+			stats[fields[1]] = fields[2]
+			continue
+		}
+		return nil, fmt.Errorf("unexpected memcached stats reply: %s", line)
+	}
+	if e := scanner.Err(); e != nil {
+		return nil, e
+	}
+	return nil, io.ErrUnexpectedEOF
+}
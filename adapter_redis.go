@@ -3,23 +3,35 @@ package cacheman
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
+// scanCount is number of keys Redis is asked to scan per iteration
+const scanCount = 100
+
+// addTagScript adds key into tag set and extends TTL of the set, it is never shortened.
+// PTTL is negative for a set without expiry, e.g. just created.
+var addTagScript = redis.NewScript(`
+redis.call("SADD", KEYS[1], ARGV[1])
+if redis.call("PTTL", KEYS[1]) < tonumber(ARGV[2]) then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 1
+`)
+
 type RedisClient struct {
-	client *redis.Client
-	ctx    context.Context
-	ttl    time.Duration
+	client    *redis.Client
+	ctx       context.Context
+	ttl       time.Duration
+	namespace string
 }
 
 // NewRedis creates big cache client
 func NewRedis(config *Config) (*RedisClient, error) {
-	ttl, e := time.ParseDuration(config.TTL)
-	if e != nil {
-		ttl, _ = time.ParseDuration(defaultTTL)
-	}
+	ttl := parseTTL(config.TTL)
 	ctx := context.Background()
 	client := redis.NewClient(&redis.Options{
 		Addr:     config.Server,
@@ -27,9 +39,10 @@ func NewRedis(config *Config) (*RedisClient, error) {
 		DB:       config.Database.(int),
 	})
 	return &RedisClient{
-		client: client,
-		ctx:    ctx,
-		ttl:    ttl,
+		client:    client,
+		ctx:       ctx,
+		ttl:       ttl,
+		namespace: config.Namespace,
 	}, nil
 }
 
@@ -42,17 +55,113 @@ func (c *RedisClient) Get(key string) ([]byte, error) {
 }
 
 func (c *RedisClient) Set(key string, value []byte) error {
-	return c.client.Set(c.ctx, key, string(value), c.ttl).Err()
+	return c.SetWithTTL(key, value, c.ttl)
+}
+
+func (c *RedisClient) SetWithTTL(key string, value []byte, ttl time.Duration) error {
+	return c.client.Set(c.ctx, key, string(value), ttl).Err()
 }
 
 func (c *RedisClient) Delete(key string) error {
 	return c.client.Del(c.ctx, key).Err()
 }
 
+// Reset deletes entries of namespace, or flushes the selected database if namespace is not set
 func (c *RedisClient) Reset() error {
-	return c.client.FlushAll(c.ctx).Err()
+	if c.namespace != "" {
+		return c.DeletePrefix(c.namespace + ".")
+	}
+	return c.client.FlushDB(c.ctx).Err()
+}
+
+func (c *RedisClient) DeletePrefix(prefix string) error {
+	return c.DeletePattern(escapeGlob(prefix) + "*")
+}
+
+func (c *RedisClient) DeletePattern(pattern string) error {
+	var cursor uint64
+	for {
+		keys, next, e := c.client.Scan(c.ctx, cursor, pattern, scanCount).Result()
+		if e != nil {
+			return e
+		}
+		if len(keys) > 0 {
+			if e = c.client.Del(c.ctx, keys...).Err(); e != nil {
+				return e
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// AddTags adds key into set of each tag. Set lives as long as its longest-lived key.
+func (c *RedisClient) AddTags(key string, tags []string, ttl time.Duration) error {
+	for _, tag := range tags {
+		if e := addTagScript.Run(c.ctx, c.client, []string{tag}, key, ttl.Milliseconds()).Err(); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+func (c *RedisClient) DeleteTags(tags ...string) error {
+	for _, tag := range tags {
+		keys, e := c.client.SMembers(c.ctx, tag).Result()
+		if e != nil {
+			return e
+		}
+		if e = c.client.Del(c.ctx, append(keys, tag)...).Err(); e != nil {
+			return e
+		}
+	}
+	return nil
 }
 
 func (c *RedisClient) Type() string {
 	return fmt.Sprintf("%T", c)
 }
+
+// Stats reports entries of database, memory use, hits, misses and evictions of Redis server
+func (c *RedisClient) Stats() (map[string]interface{}, error) {
+	entries, e := c.client.DBSize(c.ctx).Result()
+	if e != nil {
+		return nil, e
+	}
+	raw := map[string]string{}
+	for _, section := range []string{"memory", "stats"} {
+		info, e := c.client.Info(c.ctx, section).Result()
+		if e != nil {
+			return nil, e
+		}
+		for name, value := range parseRedisInfo(info) {
+			raw[name] = value
+		}
+	}
+	stats := backendStats(raw, map[string]string{
+		"used_memory":     "memoryBytes",
+		"maxmemory":       "limitBytes",
+		"keyspace_hits":   "hits",
+		"keyspace_misses": "misses",
+		"evicted_keys":    "evictions",
+	})
+	stats["entries"] = entries
+	return stats, nil
+}
+
+// parseRedisInfo parses name:value lines of INFO reply
+func parseRedisInfo(info string) map[string]string {
+	fields := map[string]string{}
+	for _, line := range strings.Split(info, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if index := strings.Index(line, ":"); index > 0 {
+			fields[line[:index]] = line[index+1:]
+		}
+	}
+	return fields
+}
@@ -0,0 +1,147 @@
+package cacheman
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strings"
+
+	echo4 "github.com/labstack/echo/v4"
+)
+
+// AdminAuth authorizes requests to admin endpoints: PurgePath, CacheInfoPath and MetricsPath.
+// Every configured check must pass. Nothing configured allows everyone.
+type AdminAuth struct {
+	// Header is name of header carrying shared secret, e.g. X-Cache-Secret
+	Header string
+	// Secret is the shared secret expected in Header
+	Secret string
+	// BearerToken is token expected in Authorization: Bearer header.
+	// If both Secret and BearerToken are set, either of them is enough.
+	BearerToken string
+	// AllowedIPs are IP addresses or CIDR ranges allowed to request, e.g. 10.0.0.0/8
+	AllowedIPs []string
+	// TrustProxyHeaders takes client IP from rightmost X-Forwarded-For entry or X-Real-IP instead of connection address.
+	// Enable it only behind a proxy which sets these headers.
+	TrustProxyHeaders bool
+	// AuthorizeRequest is custom check of request, used by every middleware
+	AuthorizeRequest func(*http.Request) bool
+	// Authorize is custom check of request, used by echo V4 middleware in addition to AuthorizeRequest.
+	// Other middlewares cannot evaluate it, so they deny every admin request if it is set.
+	Authorize func(echo4.Context) bool
+}
+
+// parseAllowedIPs parses IP addresses and CIDR ranges into networks, invalid entries are skipped
+func parseAllowedIPs(entries []string) []*net.IPNet {
+	networks := []*net.IPNet{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				continue
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		if _, network, e := net.ParseCIDR(entry); e == nil {
+			networks = append(networks, network)
+		}
+	}
+	return networks
+}
+
+// adminStatus returns 0 if request to admin endpoint is allowed by credentials and IP, or status to deny it
+func (c *Manager) adminStatus(req *http.Request, clientIP string) int {
+	auth := c.AdminAuth
+	if auth == nil {
+		return 0
+	}
+	secret := auth.Header != "" && auth.Secret != ""
+	if secret || auth.BearerToken != "" {
+		authenticated := secret && secureEqual(req.Header.Get(auth.Header), auth.Secret)
+		if !authenticated && auth.BearerToken != "" {
+			token := req.Header.Get("Authorization")
+			authenticated = len(token) > 7 && strings.EqualFold(token[:7], "Bearer ") && secureEqual(token[7:], auth.BearerToken)
+		}
+		if !authenticated {
+			return http.StatusUnauthorized
+		}
+	}
+	if len(auth.AllowedIPs) > 0 {
+		ip := net.ParseIP(clientIP)
+		allowed := false
+		for _, network := range c.allowedNetworks {
+			if ip != nil && network.Contains(ip) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return http.StatusForbidden
+		}
+	}
+	if auth.AuthorizeRequest != nil && !auth.AuthorizeRequest(req) {
+		return http.StatusForbidden
+	}
+	return 0
+}
+
+// authorizeAdmin checks request to admin endpoint, and responds 401 or 403 and returns false if it is denied.
+// Authorize evaluates AdminAuth.Authorize for echo V4 middleware, it is nil for other adapters.
+// Request is denied if AdminAuth.Authorize is set but adapter cannot evaluate it.
+func (c *Manager) authorizeAdmin(writer http.ResponseWriter, req *http.Request, authorize func() bool) bool {
+	if c.AdminAuth == nil {
+		return true
+	}
+	clientIP := remoteIP(req)
+	if c.AdminAuth.TrustProxyHeaders {
+		clientIP = realIP(req)
+	}
+	status := c.adminStatus(req, clientIP)
+	if status == 0 && c.AdminAuth.Authorize != nil && (authorize == nil || !authorize()) {
+		status = http.StatusForbidden
+	}
+	if status == 0 {
+		return true
+	}
+	c.Logger.Warn("Cache denies admin request", "uri", req.RequestURI, "method", req.Method, "ip", clientIP, "status", status)
+	if status == http.StatusUnauthorized && c.AdminAuth.BearerToken != "" {
+		writer.Header().Set("WWW-Authenticate", `Bearer realm="cacheman"`)
+	}
+	writer.WriteHeader(status)
+	return false
+}
+
+// realIP returns client IP address from X-Forwarded-For or X-Real-IP header, or IP address of connection.
+// Rightmost X-Forwarded-For entry is taken, it is added by the trusted proxy while entries before it come from client.
+func realIP(req *http.Request) string {
+	if values := req.Header.Values("X-Forwarded-For"); len(values) > 0 {
+		entries := strings.Split(values[len(values)-1], ",")
+		if ip := strings.TrimSpace(entries[len(entries)-1]); ip != "" {
+			return ip
+		}
+	}
+	if ip := req.Header.Get("X-Real-IP"); ip != "" {
+		return ip
+	}
+	return remoteIP(req)
+}
+
+// remoteIP returns IP address of connection of request
+func remoteIP(req *http.Request) string {
+	host, _, e := net.SplitHostPort(req.RemoteAddr)
+	if e != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+func secureEqual(actual, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(actual), []byte(expected)) == 1
+}
@@ -0,0 +1,133 @@
+package cacheman
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	echo4 "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type adminAuthRequest struct {
+	method     string
+	uri        string
+	header     map[string]string
+	remoteAddr string
+	status     int
+}
+
+func TestAdminAuthShouldAuthorizeAdminRequests(t *testing.T) {
+	cases := []struct {
+		name     string
+		auth     *AdminAuth
+		requests []adminAuthRequest
+	}{
+		{
+			name: "OpenWithoutAdminAuth",
+			requests: []adminAuthRequest{
+				{method: "PURGE", uri: "/purge", status: http.StatusOK},
+				{method: http.MethodGet, uri: "/cache/info", status: http.StatusOK},
+			},
+		},
+		{
+			name: "SharedSecretOrBearerToken",
+			auth: &AdminAuth{Header: "X-Cache-Secret", Secret: "s3cret", BearerToken: "t0ken"},
+			requests: []adminAuthRequest{
+				{method: "PURGE", uri: "/purge", status: http.StatusUnauthorized},
+				{method: "PURGE", uri: "/purge", header: map[string]string{"X-Cache-Secret": "wrong"}, status: http.StatusUnauthorized},
+				{method: http.MethodGet, uri: "/metrics", status: http.StatusUnauthorized},
+				{method: "PURGE", uri: "/purge", header: map[string]string{"X-Cache-Secret": "s3cret"}, status: http.StatusOK},
+				{method: http.MethodGet, uri: "/cache/info", header: map[string]string{"Authorization": "Bearer t0ken"}, status: http.StatusOK},
+			},
+		},
+		{
+			name: "AllowedIPs",
+			auth: &AdminAuth{AllowedIPs: []string{"10.0.0.0/8", "192.168.1.10"}},
+			requests: []adminAuthRequest{
+				{method: "PURGE", uri: "/purge", remoteAddr: "10.1.2.3:1234", status: http.StatusOK},
+				{method: "PURGE", uri: "/purge", remoteAddr: "192.168.1.10:1234", status: http.StatusOK},
+				{method: "PURGE", uri: "/purge", remoteAddr: "192.168.1.11:1234", status: http.StatusForbidden},
+				// Proxy headers are not trusted by default
+				{method: "PURGE", uri: "/purge", header: map[string]string{"X-Forwarded-For": "10.1.2.3"}, remoteAddr: "192.168.1.11:1234", status: http.StatusForbidden},
+			},
+		},
+		{
+			name: "TrustedProxyHeaders",
+			auth: &AdminAuth{AllowedIPs: []string{"10.0.0.0/8"}, TrustProxyHeaders: true},
+			requests: []adminAuthRequest{
+				{method: "PURGE", uri: "/purge", header: map[string]string{"X-Forwarded-For": "10.1.2.3"}, remoteAddr: "192.168.1.11:1234", status: http.StatusOK},
+				{method: "PURGE", uri: "/purge", header: map[string]string{"X-Real-IP": "10.1.2.3"}, remoteAddr: "192.168.1.11:1234", status: http.StatusOK},
+				// Client spoofs an allowed IP, proxy appends the real one
+				{method: "PURGE", uri: "/purge", header: map[string]string{"X-Forwarded-For": "10.0.0.1, 203.0.113.7"}, remoteAddr: "10.9.9.9:1234", status: http.StatusForbidden},
+				{method: "PURGE", uri: "/purge", header: map[string]string{"X-Forwarded-For": "203.0.113.7, 10.0.0.1"}, remoteAddr: "10.9.9.9:1234", status: http.StatusOK},
+			},
+		},
+		{
+			name: "CustomAuthorize",
+			auth: &AdminAuth{
+				Authorize: func(ctx echo4.Context) bool {
+					return ctx.QueryParam("admin") == "yes"
+				},
+			},
+			requests: []adminAuthRequest{
+				{method: "PURGE", uri: "/products/1", status: http.StatusForbidden},
+				{method: "PURGE", uri: "/products/1?admin=yes", status: http.StatusOK},
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := echo4.New()
+			server.Use(MiddlewareV4(&Config{
+				Enabled:       true,
+				TTL:           "1m",
+				Paths:         []string{"/products/:id"},
+				CacheInfoPath: "/cache/info",
+				PurgePath:     "/purge",
+				Metrics:       NewPrometheusMetrics(),
+				MetricsPath:   "/metrics",
+				AdminAuth:     tc.auth,
+			}, newMemoryCache()))
+
+			for _, request := range tc.requests {
+				req := httptest.NewRequest(request.method, request.uri, nil)
+				for name, value := range request.header {
+					req.Header.Set(name, value)
+				}
+				if request.remoteAddr != "" {
+					req.RemoteAddr = request.remoteAddr
+				}
+				rec := httptest.NewRecorder()
+				server.ServeHTTP(rec, req)
+
+				assert.Equal(t, request.status, rec.Code, "%s %s", request.method, request.uri)
+				if request.status == http.StatusUnauthorized {
+					assert.Equal(t, `Bearer realm="cacheman"`, rec.Header().Get("WWW-Authenticate"))
+				}
+			}
+		})
+	}
+}
+
+func TestAdminAuthShouldLogDeniedRequests(t *testing.T) {
+	logger := &recordingLogger{}
+	server := echo4.New()
+	server.Use(MiddlewareV4(&Config{
+		Enabled:   true,
+		PurgePath: "/purge",
+		AdminAuth: &AdminAuth{BearerToken: "t0ken"},
+		Logger:    logger,
+	}, newMemoryCache()))
+
+	assert.Equal(t, http.StatusUnauthorized, serveRequest(server, "PURGE", "/purge").Code)
+	assert.Contains(t, logger.levels(), "warn")
+}
+
+func TestParseAllowedIPsShouldSkipInvalidEntries(t *testing.T) {
+	networks := parseAllowedIPs([]string{"10.0.0.0/8", "::1", "not an ip", "10.0.0.0/99"})
+
+	assert.Len(t, networks, 2)
+	assert.Equal(t, "10.0.0.0/8", networks[0].String())
+	assert.Equal(t, "::1/128", networks[1].String())
+}
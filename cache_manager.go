@@ -1,15 +1,18 @@
 package cacheman
 
 import (
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"math/rand"
+	"net"
 	"net/http"
+	"os"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
+	echo3 "github.com/labstack/echo"
 	echo4 "github.com/labstack/echo/v4"
 )
 
@@ -26,13 +29,106 @@ type Manager struct {
 	ComparableExcludedRoutes []*regexp.Regexp
 	AdditionalHeaders        map[string]string
 	Namespace                string
+	TTL                      time.Duration
+	Rules                    []Rule
+	RuleCount                int
+	ComparableRules          []*ComparableRule
+	Policy                   Policy
+	VaryHeaders              []string
+	KeyFunc                  KeyFunc
+	EchoKeyFunc              EchoKeyFunc
+	StaleWhileRevalidate     time.Duration
+	StaleIfError             time.Duration
+	Coalesce                 bool
+	CoalesceTimeout          time.Duration
+	CacheInfoPath            string
+	PurgePath                string
+	MaxBodySize              int
+	Compression              string
+	Codec                    Codec
+	Metrics                  MetricsCollector
+	Logger                   Logger
+	stats                    *managerStats
+	MetricsPath              string
+	ExposeCacheKey           bool
+	CacheStatusName          string
+	AdminAuth                *AdminAuth
+	allowedNetworks          []*net.IPNet
+	CacheableStatuses        map[int]time.Duration
+	CacheableMethods         map[string]bool
+	NegativeStatuses         map[int]time.Duration
+
+	refreshing sync.Map
+	flights    flightGroup
+}
+
+// ComparableRule is a compiled route rule
+type ComparableRule struct {
+	Rule                 Rule
+	Pattern              *regexp.Regexp
+	TTL                  time.Duration
+	StaleWhileRevalidate time.Duration
+	StaleIfError         time.Duration
 }
 
 // Content is cached content
 type Content struct {
-	Status  int         `json:"status"`
-	Headers http.Header `json:"headers"`
-	Content string      `json:"content"`
+	Status       int         `json:"status"`
+	Headers      http.Header `json:"headers"`
+	Content      []byte      `json:"content"`
+	ETag         string      `json:"etag,omitempty"`
+	LastModified string      `json:"lastModified,omitempty"`
+	FreshUntil   int64       `json:"freshUntil,omitempty"`
+	StoredAt     int64       `json:"storedAt,omitempty"`
+	Negative     bool        `json:"negative,omitempty"`
+	Encoding     string      `json:"encoding,omitempty"`
+}
+
+// NewContent creates cached content. ETag and Last-Modified are preserved from header,
+// or generated from body and current time if they are missing.
+func NewContent(status int, header http.Header, body []byte) Content {
+	headers := header.Clone()
+	if headers == nil {
+		headers = http.Header{}
+	}
+	etag := headers.Get("ETag")
+	if etag == "" {
+		etag = generateETag(body)
+		headers.Set("ETag", etag)
+	}
+	lastModified := headers.Get("Last-Modified")
+	if lastModified == "" {
+		lastModified = time.Now().UTC().Format(http.TimeFormat)
+		headers.Set("Last-Modified", lastModified)
+	}
+	return Content{
+		Status:       status,
+		Headers:      headers,
+		Content:      body,
+		ETag:         etag,
+		LastModified: lastModified,
+	}
+}
+
+// Fresh returns true if content has not passed its TTL. Content without FreshUntil is always fresh.
+func (c *Content) Fresh(now time.Time) bool {
+	return c.FreshUntil == 0 || now.UnixNano() <= c.FreshUntil
+}
+
+// Age returns how long ago content was stored. Content without StoredAt has zero age.
+func (c *Content) Age(now time.Time) time.Duration {
+	if c.StoredAt == 0 || now.UnixNano() < c.StoredAt {
+		return 0
+	}
+	return time.Duration(now.UnixNano() - c.StoredAt)
+}
+
+// StaleFor returns how long content has passed its TTL
+func (c *Content) StaleFor(now time.Time) time.Duration {
+	if c.Fresh(now) {
+		return 0
+	}
+	return time.Duration(now.UnixNano() - c.FreshUntil)
 }
 
 const (
@@ -50,13 +146,43 @@ type healthCheckResult struct {
 	DeleteResult string `json:"deleteResult"`
 }
 
-var cm *Manager
 var defaultTTL = "5m"
 
 // NewCacheManager creates a cache manager
 func NewCacheManager(conf *Config, cache CacheInterface) *Manager {
 	comparableRoutes := convertToComparableRoutes(conf.Paths)
 	comparableExcludedRoutes := convertToComparableRoutes(conf.ExcludedPaths)
+	ttl := parseTTL(conf.TTL)
+	staleWhileRevalidate := parseWindow(conf.StaleWhileRevalidate)
+	staleIfError := parseWindow(conf.StaleIfError)
+	comparableRules := convertToComparableRules(conf.Rules, ttl, staleWhileRevalidate, staleIfError)
+	coalesceTimeout, e := time.ParseDuration(conf.CoalesceTimeout)
+	if e != nil || coalesceTimeout <= 0 {
+		coalesceTimeout, _ = time.ParseDuration(defaultCoalesceTimeout)
+	}
+	keyFunc := conf.KeyFunc
+	if keyFunc == nil {
+		keyFunc = RequestURIKey
+	}
+	codec := conf.Codec
+	if codec == nil {
+		codec = BinaryCodec{}
+	}
+	var metrics MetricsCollector = nopMetrics{}
+	if conf.Metrics != nil {
+		metrics = conf.Metrics
+	}
+	var allowedNetworks []*net.IPNet
+	if conf.AdminAuth != nil {
+		allowedNetworks = parseAllowedIPs(conf.AdminAuth.AllowedIPs)
+	}
+	logger := conf.Logger
+	if logger == nil {
+		logger = NopLogger{}
+		if conf.Verbose {
+			logger = NewTextLogger(os.Stdout, LogDebug)
+		}
+	}
 
 	return &Manager{
 		Enabled:                  conf.Enabled,
@@ -70,7 +196,94 @@ func NewCacheManager(conf *Config, cache CacheInterface) *Manager {
 		ExcludedRouteCount:       len(conf.ExcludedPaths),
 		AdditionalHeaders:        conf.AdditionalHeaders,
 		Namespace:                conf.Namespace,
+		TTL:                      ttl,
+		Rules:                    conf.Rules,
+		RuleCount:                len(conf.Rules),
+		ComparableRules:          comparableRules,
+		Policy:                   NewPolicy(conf.Policy),
+		VaryHeaders:              mergeVaryHeaders(conf.VaryHeaders),
+		KeyFunc:                  keyFunc,
+		EchoKeyFunc:              conf.EchoKeyFunc,
+		StaleWhileRevalidate:     staleWhileRevalidate,
+		StaleIfError:             staleIfError,
+		Coalesce:                 conf.Coalesce,
+		CoalesceTimeout:          coalesceTimeout,
+		CacheInfoPath:            conf.CacheInfoPath,
+		PurgePath:                conf.PurgePath,
+		MaxBodySize:              conf.MaxBodySize,
+		Compression:              parseCompression(conf.Compression),
+		Codec:                    codec,
+		Metrics:                  metrics,
+		Logger:                   logger,
+		stats:                    newManagerStats(),
+		MetricsPath:              conf.MetricsPath,
+		ExposeCacheKey:           conf.ExposeCacheKey,
+		CacheStatusName:          conf.CacheStatusName,
+		AdminAuth:                conf.AdminAuth,
+		allowedNetworks:          allowedNetworks,
+		CacheableStatuses:        convertToCacheableStatuses(conf.CacheableStatuses),
+		CacheableMethods:         convertToCacheableMethods(conf.CacheableMethods),
+		NegativeStatuses:         convertToNegativeStatuses(conf.NegativeStatuses, conf.NegativeTTL),
+	}
+}
+
+// parseTTL parses duration string, falls back to default TTL if it is invalid
+func parseTTL(ttl string) time.Duration {
+	duration, e := time.ParseDuration(ttl)
+	if e != nil || duration <= 0 {
+		duration, _ = time.ParseDuration(defaultTTL)
+	}
+	return duration
+}
+
+// convertToCacheableStatuses parses TTL of each cacheable status, zero means TTL of route.
+// Only 200 is cacheable if no status is given.
+func convertToCacheableStatuses(statuses map[int]string) map[int]time.Duration {
+	if len(statuses) == 0 {
+		return map[int]time.Duration{http.StatusOK: 0}
+	}
+	output := map[int]time.Duration{}
+	for status, ttl := range statuses {
+		output[status] = parseWindow(ttl)
 	}
+	return output
+}
+
+// convertToNegativeStatuses parses TTL of each negative status, status without TTL uses negativeTTL
+func convertToNegativeStatuses(statuses map[int]string, negativeTTL string) map[int]time.Duration {
+	ttl := parseWindow(negativeTTL)
+	if ttl == 0 {
+		ttl = parseWindow(defaultNegativeTTL)
+	}
+	output := map[int]time.Duration{}
+	for status, statusTTL := range statuses {
+		output[status] = ttl
+		if statusTTL != "" {
+			output[status] = parseTTL(statusTTL)
+		}
+	}
+	return output
+}
+
+// convertToCacheableMethods converts methods into set. Only GET is cacheable if no method is given.
+func convertToCacheableMethods(methods []string) map[string]bool {
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet}
+	}
+	output := map[string]bool{}
+	for _, method := range methods {
+		output[strings.ToUpper(method)] = true
+	}
+	return output
+}
+
+// parseWindow parses duration string, returns zero if it is empty or invalid
+func parseWindow(window string) time.Duration {
+	duration, e := time.ParseDuration(window)
+	if e != nil || duration < 0 {
+		return 0
+	}
+	return duration
 }
 
 // convertToComparableRoutes converts routes array of string to array of regular expression
@@ -80,39 +293,127 @@ func convertToComparableRoutes(routes []string) []*regexp.Regexp {
 	// /some/other/path/with/:variable-inside
 	output := []*regexp.Regexp{}
 	for routeIndex, routeCount := 0, len(routes); routeIndex < routeCount; routeIndex++ {
-		path := routes[routeIndex]
-		if path == "" {
-			path = "/"
-		}
-		if path[0] != '/' {
-			path = "/" + path
+		output = append(output, convertToComparableRoute(routes[routeIndex]))
+	}
+	return output
+}
+
+// convertToComparableRoute converts a route string to regular expression
+func convertToComparableRoute(path string) *regexp.Regexp {
+	if path == "" {
+		path = "/"
+	}
+	if path[0] != '/' {
+		path = "/" + path
+	}
+	fragments := strings.Split(path, "/")
+
+	for fragmentIndex, fragmentCount := 0, len(fragments); fragmentIndex < fragmentCount; fragmentIndex++ {
+		if len(fragments[fragmentIndex]) > 0 && fragments[fragmentIndex][0] == ':' {
+			fragments[fragmentIndex] = ".+"
 		}
-		fragments := strings.Split(path, "/")
+	}
+	regString := fmt.Sprintf("^%s$", strings.Join(fragments, "/"))
+	return regexp.MustCompile(regString)
+}
 
-		for fragmentIndex, fragmentCount := 0, len(fragments); fragmentIndex < fragmentCount; fragmentIndex++ {
-			if len(fragments[fragmentIndex]) > 0 && fragments[fragmentIndex][0] == ':' {
-				fragments[fragmentIndex] = ".+"
-			}
+// convertToComparableRules compiles route rules, option missing from rule uses the given default
+func convertToComparableRules(rules []Rule, defaultTTL, defaultStaleWhileRevalidate, defaultStaleIfError time.Duration) []*ComparableRule {
+	output := []*ComparableRule{}
+	for ruleIndex, ruleCount := 0, len(rules); ruleIndex < ruleCount; ruleIndex++ {
+		rule := rules[ruleIndex]
+		ttl := defaultTTL
+		if rule.TTL != "" {
+			ttl = parseTTL(rule.TTL)
+		}
+		staleWhileRevalidate := defaultStaleWhileRevalidate
+		if rule.StaleWhileRevalidate != "" {
+			staleWhileRevalidate = parseWindow(rule.StaleWhileRevalidate)
 		}
-		regString := fmt.Sprintf("^%s$", strings.Join(fragments, "/"))
-		output = append(output, regexp.MustCompile(regString))
+		staleIfError := defaultStaleIfError
+		if rule.StaleIfError != "" {
+			staleIfError = parseWindow(rule.StaleIfError)
+		}
+		output = append(output, &ComparableRule{
+			Rule:                 rule,
+			Pattern:              convertToComparableRoute(rule.Path),
+			TTL:                  ttl,
+			StaleWhileRevalidate: staleWhileRevalidate,
+			StaleIfError:         staleIfError,
+		})
 	}
 	return output
 }
 
+// StoreTTL returns how long entry is kept in cache, i.e. TTL plus the longest stale window
+func (r *ComparableRule) StoreTTL(ttl time.Duration) time.Duration {
+	if r.StaleWhileRevalidate > r.StaleIfError {
+		return ttl + r.StaleWhileRevalidate
+	}
+	return ttl + r.StaleIfError
+}
+
+// maxStoreTTL returns the longest time an entry can be kept in cache: the longest TTL of routes, rules and statuses,
+// plus the longest stale window, or TTL which Transport keeps content for revalidation, whichever is longer
+func (c *Config) maxStoreTTL() time.Duration {
+	ttl := parseTTL(c.TTL)
+	window := ttl
+	longer := func(current *time.Duration, candidate time.Duration) {
+		if candidate > *current {
+			*current = candidate
+		}
+	}
+	longer(&window, parseWindow(c.StaleWhileRevalidate))
+	longer(&window, parseWindow(c.StaleIfError))
+	for _, rule := range c.Rules {
+		if rule.TTL != "" {
+			longer(&ttl, parseTTL(rule.TTL))
+		}
+		longer(&window, parseWindow(rule.StaleWhileRevalidate))
+		longer(&window, parseWindow(rule.StaleIfError))
+	}
+	for _, statusTTL := range convertToCacheableStatuses(c.CacheableStatuses) {
+		longer(&ttl, statusTTL)
+	}
+	for _, statusTTL := range convertToNegativeStatuses(c.NegativeStatuses, c.NegativeTTL) {
+		longer(&ttl, statusTTL)
+	}
+	return ttl + window
+}
+
 // TestPath return true if path matches a route, otherwise returns false
 func (c *Manager) TestPath(path string) bool {
+	_, matched := c.MatchRule(path)
+	return matched
+}
+
+// MatchRule returns the rule matching path. Rules have higher priority than Paths.
+// Path matching Paths gets a rule with default TTL.
+func (c *Manager) MatchRule(path string) (*ComparableRule, bool) {
 	for routeIndex := 0; routeIndex < c.ExcludedRouteCount; routeIndex++ {
 		if c.ComparableExcludedRoutes[routeIndex].MatchString(path) {
-			return false
+			return nil, false
+		}
+	}
+	for ruleIndex := 0; ruleIndex < c.RuleCount; ruleIndex++ {
+		if c.ComparableRules[ruleIndex].Pattern.MatchString(path) {
+			return c.ComparableRules[ruleIndex], true
 		}
 	}
 	for routeIndex := 0; routeIndex < c.RouteCount; routeIndex++ {
 		if c.ComparableRoutes[routeIndex].MatchString(path) {
-			return true
+			return &ComparableRule{
+				Rule: Rule{
+					Path: c.Routes[routeIndex],
+				},
+				Pattern:              c.ComparableRoutes[routeIndex],
+				TTL:                  c.TTL,
+				StaleWhileRevalidate: c.StaleWhileRevalidate,
+				StaleIfError:         c.StaleIfError,
+			}, true
 		}
 	}
-	return false
+	return nil, false
 }
 
 func (c *Manager) createKey(key string) string {
@@ -124,42 +425,136 @@ func (c *Manager) createKey(key string) string {
 
 // Get gets byte content from path key
 func (c *Manager) Get(path string) ([]byte, bool) {
+	return c.get(path, "")
+}
+
+// get gets byte content from path key and observes latency of cache under route
+func (c *Manager) get(path, route string) ([]byte, bool) {
+	start := time.Now()
 	content, e := c.Cache.Get(c.createKey(path))
+	latency := time.Since(start)
+	c.observe(OperationGet, route, latency)
 	if e != nil {
-		c.Log(fmt.Sprintf("Cache misses: %s", path))
+		if isCacheMiss(e) {
+			c.Logger.Debug("Cache gets", "key", path, "route", route, "result", "miss", "latency", latency)
+		} else {
+			c.Logger.Error("Cache fails to get", "key", path, "route", route, "latency", latency, "error", e)
+		}
 		return []byte{}, false
 	}
-	c.Log(fmt.Sprintf("Cache hits: %s", path))
+	c.Logger.Debug("Cache gets", "key", path, "route", route, "result", "hit", "latency", latency)
 	return content, true
 }
 
 // Set sets byte content to path key
 func (c *Manager) Set(path string, b []byte) error {
-	c.Log(fmt.Sprintf("Cache sets: %s", path))
-	return c.Cache.Set(c.createKey(path), b)
+	return c.setWithTTL(path, b, 0, "")
+}
+
+// SetWithTTL sets byte content to path key with given TTL.
+// Cache without per-entry TTL support stores content with its own default TTL.
+func (c *Manager) SetWithTTL(path string, b []byte, ttl time.Duration) error {
+	return c.setWithTTL(path, b, ttl, "")
+}
+
+// setWithTTL sets byte content to path key with given TTL and observes latency of cache under route
+func (c *Manager) setWithTTL(path string, b []byte, ttl time.Duration, route string) error {
+	start := time.Now()
+	var e error
+	if cache, ok := c.Cache.(TTLSetter); ok && ttl > 0 {
+		e = cache.SetWithTTL(c.createKey(path), b, ttl)
+	} else {
+		e = c.Cache.Set(c.createKey(path), b)
+	}
+	latency := time.Since(start)
+	c.observe(OperationSet, route, latency)
+	if e != nil {
+		c.Logger.Error("Cache fails to set", "key", path, "route", route, "ttl", ttl, "latency", latency, "error", e)
+		return e
+	}
+	c.Logger.Debug("Cache sets", "key", path, "route", route, "ttl", ttl, "latency", latency)
+	return nil
 }
 
 // Purge all content in cache
 func (c *Manager) Purge() error {
-	c.Log("Cache purges")
-	return c.Cache.Reset()
+	c.Logger.Info("Cache purges")
+	c.count(MetricPurges, "")
+	e := c.Cache.Reset()
+	if e != nil {
+		c.Logger.Error("Cache fails to purge", "error", e)
+	}
+	return e
+}
+
+// TryWrite tries to write cached content if hit and return true, return false if miss
+func (c *Manager) TryWrite(writer http.ResponseWriter, req *http.Request) bool {
+	route := c.route(req.URL.Path)
+	if !c.keyable(req) {
+		c.count(MetricMisses, route)
+		return false
+	}
+	content, cacheKey, found := c.lookup(req, route)
+	if !found || !content.Fresh(time.Now()) {
+		c.count(MetricMisses, route)
+		return false
+	}
+	c.writeCacheStatus(writer.Header(), CacheHit, cacheKey, content)
+	if !c.writeContent(writer, req, content) {
+		for _, name := range cacheStatusHeaders {
+			writer.Header().Del(name)
+		}
+		c.count(MetricMisses, route)
+		return false
+	}
+	c.countContent(MetricHits, MetricNegativeHits, content, route)
+	return true
+}
+
+// TryWriteV3 tries to write cached content if hit and return true, return false if miss
+func (c *Manager) TryWriteV3(ctx echo3.Context) bool {
+	return c.TryWrite(ctx.Response().Writer, ctx.Request())
 }
 
 // TryWriteV4 tries to write cached content if hit and return true, return false if miss
 func (c *Manager) TryWriteV4(ctx echo4.Context) bool {
-	cacheKey := ctx.Request().RequestURI
-	stringifiedCache, e := c.Get(cacheKey)
-	if !e {
-		return false
+	return c.TryWrite(ctx.Response().Writer, c.withEchoKey(ctx.Request(), ctx))
+}
+
+// route returns path of rule matching path as route of metrics, or empty if nothing matches
+func (c *Manager) route(path string) string {
+	if rule, matched := c.MatchRule(path); matched {
+		return rule.Rule.Path
+	}
+	return ""
+}
+
+// lookup gets cached content of request and its cache key
+func (c *Manager) lookup(req *http.Request, route string) (*Content, string, bool) {
+	cacheKey := c.lookupKey(c.requestKey(req), req)
+	stringifiedCache, found := c.get(cacheKey, route)
+	if !found {
+		return nil, cacheKey, false
 	}
 
 	var content Content
-	err := json.Unmarshal(stringifiedCache, &content)
+	err := c.Codec.Decode(stringifiedCache, &content)
+	if err != nil {
+		c.Logger.Warn("Cache fails to decode", "key", cacheKey, "route", route, "error", err)
+		c.count(MetricDecodeErrors, route)
+		return nil, cacheKey, false
+	}
+	return &content, cacheKey, true
+}
+
+// writeContent writes cached content out, or 304 Not Modified if conditional request matches the content
+func (c *Manager) writeContent(writer http.ResponseWriter, req *http.Request, content *Content) bool {
+	byteContent, encoding, err := responseBody(req, content)
 	if err != nil {
+		c.Logger.Error("Cache fails to read content", "uri", req.RequestURI, "encoding", content.Encoding, "error", err)
 		return false
 	}
 
-	writer := ctx.Response().Writer
 	for headerKey, headerValues := range content.Headers {
 		for _, headerValue := range headerValues {
 			writer.Header().Set(headerKey, headerValue)
@@ -168,21 +563,109 @@ func (c *Manager) TryWriteV4(ctx echo4.Context) bool {
 	for headerKey, headerValue := range c.AdditionalHeaders {
 		writer.Header().Set(headerKey, headerValue)
 	}
+	if rule, matched := c.MatchRule(req.URL.Path); matched {
+		for headerKey, headerValue := range rule.Rule.AdditionalHeaders {
+			writer.Header().Set(headerKey, headerValue)
+		}
+	}
+	if content.Encoding != "" {
+		addVary(writer.Header(), "Accept-Encoding")
+	}
+	if encoding != "" {
+		writer.Header().Set("Content-Encoding", encoding)
+		writer.Header().Set("ETag", weakETag(content.ETag))
+		writer.Header().Del("Content-Length")
+	}
 
+	if content.Status == http.StatusOK && isNotModified(req, content.ETag, content.LastModified) {
+		c.Logger.Debug("Cache not modified", "uri", req.RequestURI, "status", http.StatusNotModified)
+		writer.Header().Del("Content-Type")
+		writer.Header().Del("Content-Length")
+		writer.WriteHeader(http.StatusNotModified)
+		return true
+	}
+
+	if content.Negative {
+		c.Logger.Debug("Cache serves negative", "uri", req.RequestURI, "status", content.Status)
+	}
 	writer.WriteHeader(content.Status)
-	byteContent, err := base64.StdEncoding.DecodeString(content.Content)
-	if err != nil {
-		return false
+	if req.Method != http.MethodHead {
+		if _, e := writer.Write(byteContent); e != nil {
+			c.Logger.Warn("Cache fails to write content", "uri", req.RequestURI, "status", content.Status, "error", e)
+		}
 	}
-	writer.Write(byteContent)
 	return true
 }
 
-// Log prints log message
-func (c *Manager) Log(msg string) {
-	if c.Verbose {
-		fmt.Println(msg)
+// store stores captured response of request if it is cacheable, and returns the stored content and its variant
+func (c *Manager) store(req *http.Request, rule *ComparableRule, interceptor *Interceptor) (*Content, variant) {
+	// Response of HEAD has no content, it must not replace content of GET
+	if req.Method == http.MethodHead {
+		return nil, variant{}
+	}
+	status, header := interceptor.Status(), interceptor.Header()
+	// Store into cache only if status is cacheable, whole response is captured and policy allows
+	statusTTL, cacheableStatus := c.statusTTL(status, rule.TTL)
+	if !cacheableStatus || !interceptor.Complete() {
+		return nil, variant{}
+	}
+	ttl, storable := c.Policy.StoreTTL(header, statusTTL)
+	if !storable {
+		return nil, variant{}
+	}
+	content := NewContent(status, header, interceptor.Content())
+	c.compress(&content, interceptor.Content())
+	now := time.Now()
+	content.StoredAt = now.UnixNano()
+	content.FreshUntil = now.Add(ttl).UnixNano()
+	for _, name := range cacheStatusHeaders {
+		content.Headers.Del(name)
+	}
+	content.Negative = negative(status)
+	route := rule.Rule.Path
+	stringifiedCache, e := c.Codec.Encode(&content)
+	if e != nil {
+		c.Logger.Error("Cache fails to encode", "uri", req.RequestURI, "route", route, "status", status, "error", e)
+		c.count(MetricStoreFailures, route)
+		return nil, variant{}
+	}
+	storeTTL := rule.StoreTTL(ttl)
+	stored, cacheable := c.storeKey(c.requestKey(req), req, header, storeTTL)
+	cacheKey := stored.key
+	if !cacheable {
+		return nil, variant{}
 	}
+	if e := c.setWithTTL(cacheKey, stringifiedCache, storeTTL, route); e != nil {
+		c.count(MetricStoreFailures, route)
+		return nil, variant{}
+	}
+	c.countContent(MetricStores, MetricNegativeStores, &content, route)
+	if e := c.tag(cacheKey, append(append([]string{}, rule.Rule.Tags...), interceptor.Tags()...), storeTTL); e != nil {
+		c.Logger.Error("Cache fails to tag", "key", cacheKey, "route", route, "error", e)
+	}
+	return &content, stored
+}
+
+// statusTTL returns TTL of response with status, or false if status is not cacheable
+func (c *Manager) statusTTL(status int, ttl time.Duration) (time.Duration, bool) {
+	if negativeTTL, cacheable := c.NegativeStatuses[status]; cacheable {
+		return negativeTTL, true
+	}
+	statusTTL, cacheable := c.CacheableStatuses[status]
+	if !cacheable {
+		return 0, false
+	}
+	if statusTTL > 0 {
+		return statusTTL, true
+	}
+	return ttl, true
+}
+
+// Log logs debug message.
+//
+// Deprecated: use Logger, which supports levels and fields.
+func (c *Manager) Log(msg string) {
+	c.Logger.Debug(msg)
 }
 
 func (c *Manager) healthCheck() *healthCheckResult {
@@ -209,7 +692,7 @@ func (c *Manager) healthCheck() *healthCheckResult {
 			result.GetResult = HealthCheckPassed
 		}
 		if result.GetResult == HealthCheckPassed {
-			err = c.Cache.Delete(cacheKey)
+			err = c.Cache.Delete(c.createKey(cacheKey))
 			if err != nil {
 				result.DeleteResult = HealthCheckFailed
 			} else {
@@ -220,13 +703,28 @@ func (c *Manager) healthCheck() *healthCheckResult {
 	return result
 }
 
-// WriteInfoV4 print cacheman information out to client
-func (c *Manager) WriteInfoV4(ctx echo4.Context) {
-	healthResult := c.healthCheck()
-
-	response := map[string]interface{}{
-		"type":            c.Cache.Type(),
-		"operationHealth": healthResult,
+// WriteInfo print cacheman information out to client, as JSON or as text if format query is text
+func (c *Manager) WriteInfo(writer http.ResponseWriter, req *http.Request) {
+	info := c.info()
+	if req.URL.Query().Get("format") == InfoFormatText {
+		writer.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+		writer.WriteHeader(http.StatusOK)
+		writer.Write([]byte(formatInfoText(info)))
+		return
 	}
-	ctx.JSON(200, response)
+	writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	writer.WriteHeader(http.StatusOK)
+	if e := json.NewEncoder(writer).Encode(info); e != nil {
+		c.Logger.Error("Cache fails to write info", "error", e)
+	}
+}
+
+// WriteInfoV3 print cacheman information out to client, as JSON or as text if format query is text
+func (c *Manager) WriteInfoV3(ctx echo3.Context) {
+	c.WriteInfo(ctx.Response(), ctx.Request())
+}
+
+// WriteInfoV4 print cacheman information out to client, as JSON or as text if format query is text
+func (c *Manager) WriteInfoV4(ctx echo4.Context) {
+	c.WriteInfo(ctx.Response(), ctx.Request())
 }
@@ -1,7 +1,17 @@
 package cacheman
 
 import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	echo4 "github.com/labstack/echo/v4"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -31,6 +41,71 @@ func (o *MockCache) Reset() error {
 	return args.Error(0)
 }
 
+func (o *MockCache) Type() string {
+	return "MockCache"
+}
+
+type memoryCache struct {
+	lock    sync.Mutex
+	entries map[string][]byte
+}
+
+func newMemoryCache() *memoryCache {
+	return &memoryCache{
+		entries: map[string][]byte{},
+	}
+}
+
+func (o *memoryCache) Get(k string) ([]byte, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if b, ok := o.entries[k]; ok {
+		return b, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func (o *memoryCache) Set(k string, b []byte) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.entries[k] = b
+	return nil
+}
+
+func (o *memoryCache) Delete(k string) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	delete(o.entries, k)
+	return nil
+}
+
+func (o *memoryCache) Reset() error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.entries = map[string][]byte{}
+	return nil
+}
+
+func (o *memoryCache) Type() string {
+	return "memoryCache"
+}
+
+// serveRequest serves a request without body by server and returns its response
+func serveRequest(server http.Handler, method, uri string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(method, uri, nil))
+	return rec
+}
+
+type MockTTLCache struct {
+	MockCache
+}
+
+func (o *MockTTLCache) SetWithTTL(k string, b []byte, ttl time.Duration) error {
+	args := o.Called(k, b, ttl)
+	return args.Error(0)
+}
+
 func TestMatchPathWithWildcard(t *testing.T) {
 	conf := &Config{
 		Enabled: true,
@@ -196,3 +271,378 @@ func TestMatchPathWithWildcardAndExcludsionWildcard(t *testing.T) {
 
 	assert.False(t, result)
 }
+
+func TestMatchRuleWithRuleTTL(t *testing.T) {
+	conf := &Config{
+		Enabled: true,
+		TTL:     "1m",
+		Rules: []Rule{
+			{Path: "/stock/:id", TTL: "10s"},
+			{Path: "/products/:id", TTL: "1h"},
+		},
+	}
+	cm := NewCacheManager(conf, nil)
+
+	rule, matched := cm.MatchRule("/stock/123")
+
+	assert.True(t, matched)
+	assert.Equal(t, 10*time.Second, rule.TTL)
+}
+
+func TestMatchRuleWithoutRuleTTLShouldUseDefaultTTL(t *testing.T) {
+	conf := &Config{
+		Enabled: true,
+		TTL:     "1m",
+		Rules: []Rule{
+			{Path: "/stock/:id"},
+		},
+		Paths: []string{
+			"/products/:id",
+		},
+	}
+	cm := NewCacheManager(conf, nil)
+
+	rule, matched := cm.MatchRule("/stock/123")
+	assert.True(t, matched)
+	assert.Equal(t, time.Minute, rule.TTL)
+
+	rule, matched = cm.MatchRule("/products/123")
+	assert.True(t, matched)
+	assert.Equal(t, time.Minute, rule.TTL)
+}
+
+func TestMatchRuleShouldPreferRuleOverPath(t *testing.T) {
+	conf := &Config{
+		Enabled: true,
+		TTL:     "1m",
+		Rules: []Rule{
+			{Path: "/products/:id", TTL: "1h"},
+		},
+		Paths: []string{
+			"/.*",
+		},
+	}
+	cm := NewCacheManager(conf, nil)
+
+	rule, matched := cm.MatchRule("/products/123")
+
+	assert.True(t, matched)
+	assert.Equal(t, time.Hour, rule.TTL)
+}
+
+func TestMatchRuleWithExclusion(t *testing.T) {
+	conf := &Config{
+		Enabled: true,
+		TTL:     "1m",
+		Rules: []Rule{
+			{Path: "/products/:id", TTL: "1h"},
+		},
+		ExcludedPaths: []string{
+			"/products/0",
+		},
+	}
+	cm := NewCacheManager(conf, nil)
+
+	_, matched := cm.MatchRule("/products/0")
+
+	assert.False(t, matched)
+}
+
+func TestSetWithTTLShouldPassTTLToCache(t *testing.T) {
+	cache := new(MockTTLCache)
+	cache.On("SetWithTTL", "ns./stock/1", []byte("data"), 10*time.Second).Return(nil)
+	cm := NewCacheManager(&Config{Namespace: "ns"}, cache)
+
+	e := cm.SetWithTTL("/stock/1", []byte("data"), 10*time.Second)
+
+	assert.NoError(t, e)
+	cache.AssertCalled(t, "SetWithTTL", "ns./stock/1", []byte("data"), 10*time.Second)
+}
+
+func TestSetWithTTLShouldFallBackToSetIfCacheDoesNotSupportTTL(t *testing.T) {
+	cache := new(MockCache)
+	cache.On("Set", "/stock/1", []byte("data")).Return(nil)
+	cm := NewCacheManager(&Config{}, cache)
+
+	e := cm.SetWithTTL("/stock/1", []byte("data"), 10*time.Second)
+
+	assert.NoError(t, e)
+	cache.AssertCalled(t, "Set", "/stock/1", []byte("data"))
+}
+
+func TestMiddlewareV4ShouldStoreWithRuleTTL(t *testing.T) {
+	cache := new(MockTTLCache)
+	cache.On("Get", mock.AnythingOfType("string")).Return([]byte{}, errors.New("miss"))
+	cache.On("SetWithTTL", "/stock/1", mock.AnythingOfType("[]uint8"), 10*time.Second).Return(nil)
+	cache.On("Delete", "/stock/1#vary").Return(nil)
+	conf := &Config{
+		Enabled: true,
+		TTL:     "1m",
+		Rules: []Rule{
+			{Path: "/stock/:id", TTL: "10s"},
+		},
+	}
+	server := echo4.New()
+	server.Use(MiddlewareV4(conf, cache))
+	server.GET("/stock/:id", func(ctx echo4.Context) error {
+		return ctx.String(http.StatusOK, "in stock")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/stock/1", nil)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "in stock", rec.Body.String())
+	cache.AssertNumberOfCalls(t, "SetWithTTL", 1)
+}
+
+func TestNewContentShouldPreserveETagAndLastModified(t *testing.T) {
+	header := http.Header{}
+	header.Set("ETag", `"v1"`)
+	header.Set("Last-Modified", "Wed, 21 Oct 2015 07:28:00 GMT")
+
+	content := NewContent(http.StatusOK, header, []byte("body"))
+
+	assert.Equal(t, `"v1"`, content.ETag)
+	assert.Equal(t, "Wed, 21 Oct 2015 07:28:00 GMT", content.LastModified)
+}
+
+func TestNewContentShouldGenerateETagAndLastModified(t *testing.T) {
+	header := http.Header{}
+
+	content := NewContent(http.StatusOK, header, []byte("body"))
+
+	assert.Equal(t, generateETag([]byte("body")), content.ETag)
+	assert.Equal(t, content.ETag, content.Headers.Get("ETag"))
+	assert.NotEmpty(t, content.Headers.Get("Last-Modified"))
+	assert.Empty(t, header.Get("ETag"))
+}
+
+func TestTryWriteV4ShouldAnswerNotModifiedOnMatchingETag(t *testing.T) {
+	content := NewContent(http.StatusOK, http.Header{"Content-Type": {"text/plain"}}, []byte("body"))
+	stringifiedCache, _ := json.Marshal(content)
+	cache := new(MockCache)
+	cache.On("Get", "/products/1#vary").Return([]byte{}, errors.New("miss"))
+	cache.On("Get", "/products/1").Return(stringifiedCache, nil)
+	cm := NewCacheManager(&Config{Enabled: true, Paths: []string{"/products/:id"}}, cache)
+
+	req := httptest.NewRequest(http.MethodGet, "/products/1", nil)
+	req.Header.Set("If-None-Match", content.ETag)
+	rec := httptest.NewRecorder()
+	ctx := echo4.New().NewContext(req, rec)
+
+	result := cm.TryWriteV4(ctx)
+
+	assert.True(t, result)
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Equal(t, content.ETag, rec.Header().Get("ETag"))
+	assert.Empty(t, rec.Body.String())
+}
+
+func TestTryWriteV4ShouldWriteFullContentOnMismatchETag(t *testing.T) {
+	content := NewContent(http.StatusOK, http.Header{"Content-Type": {"text/plain"}}, []byte("body"))
+	stringifiedCache, _ := json.Marshal(content)
+	cache := new(MockCache)
+	cache.On("Get", "/products/1#vary").Return([]byte{}, errors.New("miss"))
+	cache.On("Get", "/products/1").Return(stringifiedCache, nil)
+	cm := NewCacheManager(&Config{Enabled: true, Paths: []string{"/products/:id"}}, cache)
+
+	req := httptest.NewRequest(http.MethodGet, "/products/1", nil)
+	req.Header.Set("If-None-Match", `"stale"`)
+	rec := httptest.NewRecorder()
+	ctx := echo4.New().NewContext(req, rec)
+
+	result := cm.TryWriteV4(ctx)
+
+	assert.True(t, result)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "body", rec.Body.String())
+}
+
+func TestMiddlewareV4InstancesShouldBeIndependent(t *testing.T) {
+	adminCache := newMemoryCache()
+	publicCache := newMemoryCache()
+	server := echo4.New()
+	admin := server.Group("/admin", MiddlewareV4(&Config{
+		Enabled:   true,
+		TTL:       "1m",
+		Paths:     []string{"/admin/.*"},
+		Namespace: "admin",
+		PurgePath: "/admin/purge",
+	}, adminCache))
+	public := server.Group("/public", MiddlewareV4WithManager(NewCacheManager(&Config{
+		Enabled:   true,
+		TTL:       "1m",
+		Paths:     []string{"/public/.*"},
+		Namespace: "public",
+	}, publicCache)))
+	admin.GET("/report", func(ctx echo4.Context) error {
+		return ctx.String(http.StatusOK, "report")
+	})
+	public.GET("/news", func(ctx echo4.Context) error {
+		return ctx.String(http.StatusOK, "news")
+	})
+
+	for _, uri := range []string{"/admin/report", "/public/news"} {
+		req := httptest.NewRequest(http.MethodGet, uri, nil)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	_, e := adminCache.Get("admin./admin/report")
+	assert.NoError(t, e)
+	_, e = publicCache.Get("public./public/news")
+	assert.NoError(t, e)
+	assert.Len(t, adminCache.entries, 1)
+	assert.Len(t, publicCache.entries, 1)
+}
+
+func TestMiddlewareV4ShouldCacheWholeMultiChunkResponse(t *testing.T) {
+	calls := 0
+	server := echo4.New()
+	server.Use(MiddlewareV4(&Config{Enabled: true, TTL: "1m", Paths: []string{"/items"}}, newMemoryCache()))
+	server.GET("/items", func(ctx echo4.Context) error {
+		calls++
+		ctx.Response().Header().Set(echo4.HeaderContentType, echo4.MIMEApplicationJSON)
+		ctx.Response().WriteHeader(http.StatusOK)
+		encoder := json.NewEncoder(ctx.Response())
+		for index := 1; index <= 3; index++ {
+			encoder.Encode(map[string]int{"id": index})
+			ctx.Response().Flush()
+		}
+		return nil
+	})
+
+	expected := "{\"id\":1}\n{\"id\":2}\n{\"id\":3}\n"
+	for index := 0; index < 2; index++ {
+		req := httptest.NewRequest(http.MethodGet, "/items", nil)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		assert.Equal(t, expected, rec.Body.String())
+	}
+	assert.Equal(t, 1, calls)
+}
+
+func TestMiddlewareV4ShouldNotCacheResponseBeyondMaxBodySize(t *testing.T) {
+	cache := newMemoryCache()
+	server := echo4.New()
+	server.Use(MiddlewareV4(&Config{Enabled: true, TTL: "1m", Paths: []string{"/large"}, MaxBodySize: 4}, cache))
+	server.GET("/large", func(ctx echo4.Context) error {
+		return ctx.String(http.StatusOK, "too large")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/large", nil)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	assert.Equal(t, "too large", rec.Body.String())
+	assert.Empty(t, cache.entries)
+}
+
+func TestNewCacheManagerShouldCacheOnlyGETAnd200ByDefault(t *testing.T) {
+	cm := NewCacheManager(&Config{}, nil)
+
+	assert.Equal(t, map[int]time.Duration{http.StatusOK: 0}, cm.CacheableStatuses)
+	assert.Equal(t, map[string]bool{http.MethodGet: true}, cm.CacheableMethods)
+}
+
+func TestStatusTTL(t *testing.T) {
+	cm := NewCacheManager(&Config{
+		CacheableStatuses: map[int]string{
+			http.StatusOK:       "",
+			http.StatusNotFound: "10s",
+		},
+	}, nil)
+
+	ttl, cacheable := cm.statusTTL(http.StatusOK, time.Minute)
+	assert.True(t, cacheable)
+	assert.Equal(t, time.Minute, ttl)
+
+	ttl, cacheable = cm.statusTTL(http.StatusNotFound, time.Minute)
+	assert.True(t, cacheable)
+	assert.Equal(t, 10*time.Second, ttl)
+
+	_, cacheable = cm.statusTTL(http.StatusGone, time.Minute)
+	assert.False(t, cacheable)
+}
+
+func TestMiddlewareV4ShouldCacheConfiguredStatusWithItsTTL(t *testing.T) {
+	cache := new(MockTTLCache)
+	cache.On("Get", mock.AnythingOfType("string")).Return([]byte{}, errors.New("miss"))
+	cache.On("SetWithTTL", "/old", mock.AnythingOfType("[]uint8"), time.Hour).Return(nil)
+	cache.On("Delete", "/old#vary").Return(nil)
+	conf := &Config{
+		Enabled: true,
+		TTL:     "1m",
+		Paths:   []string{"/old"},
+		CacheableStatuses: map[int]string{
+			http.StatusPermanentRedirect: "1h",
+		},
+	}
+	server := echo4.New()
+	server.Use(MiddlewareV4(conf, cache))
+	server.GET("/old", func(ctx echo4.Context) error {
+		return ctx.Redirect(http.StatusPermanentRedirect, "/new")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/old", nil)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusPermanentRedirect, rec.Code)
+	cache.AssertNumberOfCalls(t, "SetWithTTL", 1)
+}
+
+func TestMiddlewareV4ShouldServeHEADFromGETContent(t *testing.T) {
+	server := echo4.New()
+	server.Use(MiddlewareV4(&Config{
+		Enabled:          true,
+		TTL:              "1m",
+		Paths:            []string{"/products/:id"},
+		CacheableMethods: []string{"get", "head"},
+	}, newMemoryCache()))
+	server.GET("/products/:id", func(ctx echo4.Context) error {
+		return ctx.String(http.StatusOK, "product")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/products/1", nil)
+	server.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodHead, "/products/1", nil)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/plain; charset=UTF-8", rec.Header().Get("Content-Type"))
+	assert.Empty(t, rec.Body.String())
+}
+
+func TestMiddlewareV4ShouldCachePOSTByBody(t *testing.T) {
+	calls := 0
+	server := echo4.New()
+	server.Use(MiddlewareV4(&Config{
+		Enabled:          true,
+		TTL:              "1m",
+		Paths:            []string{"/graphql"},
+		CacheableMethods: []string{"POST"},
+	}, newMemoryCache()))
+	server.POST("/graphql", func(ctx echo4.Context) error {
+		calls++
+		body, _ := ioutil.ReadAll(ctx.Request().Body)
+		return ctx.String(http.StatusOK, "result of "+string(body))
+	})
+
+	request := func(query string) string {
+		req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(query))
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+
+	assert.Equal(t, "result of {a}", request("{a}"))
+	assert.Equal(t, "result of {b}", request("{b}"))
+	assert.Equal(t, "result of {a}", request("{a}"))
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,71 @@
+package cacheman
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Values of X-Cache header
+const (
+	// CacheHit tells that response is served from fresh cached content
+	CacheHit = "HIT"
+	// CacheMiss tells that response is served by handler after cache lookup
+	CacheMiss = "MISS"
+	// CacheBypass tells that response is served by handler without cache lookup, e.g. by policy
+	CacheBypass = "BYPASS"
+	// CacheStale tells that response is served from stale cached content
+	CacheStale = "STALE"
+	// CacheRevalidated tells that stale cached content is confirmed by server with 304 Not Modified, used by Transport
+	CacheRevalidated = "REVALIDATED"
+)
+
+// cacheStatusHeaders are added by cacheman to responses, they are never stored
+var cacheStatusHeaders = []string{"X-Cache", "Age", "X-Cache-Key", "Cache-Status"}
+
+// writeCacheStatus sets cache status headers of response. Content is cached content found by lookup, if any.
+func (c *Manager) writeCacheStatus(header http.Header, status string, cacheKey string, content *Content) {
+	now := time.Now()
+	header.Set("X-Cache", status)
+	if content != nil && (status == CacheHit || status == CacheStale) {
+		header.Set("Age", strconv.FormatInt(int64(content.Age(now)/time.Second), 10))
+	} else {
+		header.Del("Age")
+	}
+	if c.ExposeCacheKey && cacheKey != "" {
+		header.Set("X-Cache-Key", cacheKey)
+	}
+	if c.CacheStatusName != "" {
+		header.Set("Cache-Status", c.cacheStatus(status, cacheKey, content, now))
+	}
+}
+
+// cacheStatus returns value of Cache-Status header as defined by RFC 9211
+func (c *Manager) cacheStatus(status string, cacheKey string, content *Content, now time.Time) string {
+	params := []string{c.CacheStatusName}
+	switch {
+	case status == CacheHit || status == CacheStale:
+		params = append(params, "hit")
+		if content != nil && content.FreshUntil != 0 {
+			// Negative TTL tells that content is stale
+			params = append(params, fmt.Sprintf("ttl=%d", (content.FreshUntil-now.UnixNano())/int64(time.Second)))
+		}
+	case status == CacheBypass:
+		params = append(params, "fwd=request")
+	case content != nil:
+		params = append(params, "fwd=stale")
+	default:
+		params = append(params, "fwd=miss")
+	}
+	if c.ExposeCacheKey && cacheKey != "" {
+		params = append(params, "key="+quoteStructuredString(cacheKey))
+	}
+	return strings.Join(params, "; ")
+}
+
+// quoteStructuredString quotes string as structured field value of RFC 8941
+func quoteStructuredString(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
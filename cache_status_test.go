@@ -0,0 +1,115 @@
+package cacheman
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	echo4 "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// productHandler responds status, which can be changed by test, with body product
+func productHandler(status *int) echo4.HandlerFunc {
+	return func(ctx echo4.Context) error {
+		return ctx.String(*status, "product")
+	}
+}
+
+func TestContentAge(t *testing.T) {
+	now := time.Now()
+
+	assert.Equal(t, time.Duration(0), (&Content{}).Age(now))
+	assert.Equal(t, time.Minute, (&Content{StoredAt: now.Add(-time.Minute).UnixNano()}).Age(now))
+}
+
+func TestMiddlewareV4ShouldAddXCacheMissThenHitWithAge(t *testing.T) {
+	status := http.StatusOK
+	cache := newMemoryCache()
+	server := newEchoV4Server(&Config{
+		Enabled: true,
+		TTL:     "1m",
+		Paths:   []string{"/products/:id"},
+	}, cache, productHandler(&status))
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/products/1", nil))
+	assert.Equal(t, CacheMiss, rec.Header().Get("X-Cache"))
+	assert.Equal(t, "", rec.Header().Get("Age"))
+	assert.Equal(t, "", rec.Header().Get("X-Cache-Key"))
+	assert.Equal(t, "", rec.Header().Get("Cache-Status"))
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/products/1", nil))
+	assert.Equal(t, CacheHit, rec.Header().Get("X-Cache"))
+	assert.Equal(t, "0", rec.Header().Get("Age"))
+
+	var content Content
+	assert.NoError(t, BinaryCodec{}.Decode(cache.entries["/products/1"], &content))
+	assert.NotZero(t, content.StoredAt)
+	for _, name := range cacheStatusHeaders {
+		assert.Empty(t, content.Headers.Get(name))
+	}
+}
+
+func TestMiddlewareV4ShouldAddXCacheBypass(t *testing.T) {
+	status := http.StatusOK
+	server := newEchoV4Server(&Config{
+		Enabled: true,
+		TTL:     "1m",
+		Paths:   []string{"/products/:id"},
+		Policy:  PolicyRespectHeaders,
+	}, newMemoryCache(), productHandler(&status))
+
+	req := httptest.NewRequest(http.MethodGet, "/products/1", nil)
+	req.Header.Set("Cache-Control", "no-cache")
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	assert.Equal(t, CacheBypass, rec.Header().Get("X-Cache"))
+}
+
+func TestMiddlewareV4ShouldAddXCacheStaleWithAge(t *testing.T) {
+	status := http.StatusInternalServerError
+	cache := newMemoryCache()
+	server := newEchoV4Server(&Config{
+		Enabled:      true,
+		TTL:          "1m",
+		Paths:        []string{"/products/:id"},
+		StaleIfError: "1h",
+	}, cache, productHandler(&status))
+	now := time.Now()
+	content := NewContent(http.StatusOK, http.Header{}, []byte("stale product"))
+	content.StoredAt = now.Add(-2 * time.Minute).UnixNano()
+	content.FreshUntil = now.Add(-time.Minute).UnixNano()
+	cache.entries["/products/1"], _ = BinaryCodec{}.Encode(&content)
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/products/1", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "stale product", rec.Body.String())
+	assert.Equal(t, CacheStale, rec.Header().Get("X-Cache"))
+	assert.Equal(t, "120", rec.Header().Get("Age"))
+}
+
+func TestMiddlewareV4ShouldAddCacheKeyAndCacheStatus(t *testing.T) {
+	status := http.StatusOK
+	server := newEchoV4Server(&Config{
+		Enabled:         true,
+		TTL:             "1m",
+		Paths:           []string{"/products/:id"},
+		ExposeCacheKey:  true,
+		CacheStatusName: "cacheman",
+	}, newMemoryCache(), productHandler(&status))
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/products/1", nil))
+	assert.Equal(t, "/products/1", rec.Header().Get("X-Cache-Key"))
+	assert.Equal(t, `cacheman; fwd=miss; key="/products/1"`, rec.Header().Get("Cache-Status"))
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/products/1", nil))
+	assert.Regexp(t, `^cacheman; hit; ttl=(59|60); key="/products/1"$`, rec.Header().Get("Cache-Status"))
+}
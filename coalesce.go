@@ -0,0 +1,62 @@
+package cacheman
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultCoalesceTimeout is how long a request waits for response of the same cache key by default
+var defaultCoalesceTimeout = "5s"
+
+// flight is a handler run shared by concurrent requests of the same cache key
+type flight struct {
+	done    chan struct{}
+	content *Content
+	variant variant
+}
+
+// flightGroup deduplicates concurrent handler runs by cache key
+type flightGroup struct {
+	lock    sync.Mutex
+	flights map[string]*flight
+}
+
+// join returns flight of key. The first caller becomes leader, gets true, and must call finish.
+func (g *flightGroup) join(key string) (*flight, bool) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if g.flights == nil {
+		g.flights = map[string]*flight{}
+	}
+	if f, ok := g.flights[key]; ok {
+		return f, false
+	}
+	f := &flight{
+		done: make(chan struct{}),
+	}
+	g.flights[key] = f
+	return f, true
+}
+
+// finish publishes content stored by leader, nil if response was not stored, and its variant to waiting requests.
+// Variant tells which waiting requests the content is for, as response may vary by headers of request.
+func (g *flightGroup) finish(key string, f *flight, content *Content, stored variant) {
+	g.lock.Lock()
+	delete(g.flights, key)
+	g.lock.Unlock()
+	f.content = content
+	f.variant = stored
+	close(f.done)
+}
+
+// wait waits for leader and returns its content and variant, or false if leader did not store any content in time
+func (f *flight) wait(timeout time.Duration) (*Content, variant, bool) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-f.done:
+		return f.content, f.variant, f.content != nil
+	case <-timer.C:
+		return nil, variant{}, false
+	}
+}
@@ -0,0 +1,206 @@
+package cacheman
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	echo4 "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlightGroupShouldHaveOneLeaderPerKey(t *testing.T) {
+	group := &flightGroup{}
+
+	first, firstLeader := group.join("/a")
+	second, secondLeader := group.join("/a")
+	_, otherLeader := group.join("/b")
+
+	assert.True(t, firstLeader)
+	assert.False(t, secondLeader)
+	assert.True(t, otherLeader)
+	assert.Same(t, first, second)
+}
+
+func TestFlightWaitShouldReceiveLeaderContent(t *testing.T) {
+	group := &flightGroup{}
+	f, _ := group.join("/a")
+	content := &Content{Status: http.StatusOK}
+	stored := variant{key: "/a#vary=1", names: []string{"Accept-Language"}}
+
+	go group.finish("/a", f, content, stored)
+	received, receivedVariant, ok := f.wait(time.Second)
+
+	assert.True(t, ok)
+	assert.Same(t, content, received)
+	assert.Equal(t, stored, receivedVariant)
+}
+
+func TestFlightWaitShouldTimeOut(t *testing.T) {
+	group := &flightGroup{}
+	f, _ := group.join("/a")
+
+	_, _, ok := f.wait(10 * time.Millisecond)
+
+	assert.False(t, ok)
+}
+
+func TestMiddlewareV4WithCoalesceShouldRunHandlerOnce(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	conf := &Config{
+		Enabled:  true,
+		TTL:      "1m",
+		Paths:    []string{"/products/:id"},
+		Coalesce: true,
+	}
+	server := echo4.New()
+	server.Use(MiddlewareV4(conf, newMemoryCache()))
+	server.GET("/products/:id", func(ctx echo4.Context) error {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return ctx.String(http.StatusOK, "product")
+	})
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 20)
+	for index := range bodies {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, "/products/1", nil)
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, req)
+			bodies[index] = rec.Body.String()
+		}(index)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	for _, body := range bodies {
+		assert.Equal(t, "product", body)
+	}
+}
+
+func TestMiddlewareV4WithCoalesceShouldRunHandlerAfterTimeout(t *testing.T) {
+	var calls int32
+	conf := &Config{
+		Enabled:         true,
+		TTL:             "1m",
+		Paths:           []string{"/products/:id"},
+		Coalesce:        true,
+		CoalesceTimeout: "10ms",
+	}
+	server := echo4.New()
+	server.Use(MiddlewareV4(conf, newMemoryCache()))
+	server.GET("/products/:id", func(ctx echo4.Context) error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			time.Sleep(100 * time.Millisecond)
+		}
+		return ctx.String(http.StatusOK, "product")
+	})
+
+	var wg sync.WaitGroup
+	for index := 0; index < 2; index++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, "/products/1", nil)
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, req)
+			assert.Equal(t, "product", rec.Body.String())
+		}()
+		time.Sleep(5 * time.Millisecond)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestMiddlewareV4WithCoalesceShouldRunHandlerForOtherVariant(t *testing.T) {
+	var calls int32
+	entered := make(chan struct{}, 2)
+	release := make(chan struct{})
+	conf := &Config{
+		Enabled:  true,
+		TTL:      "1m",
+		Paths:    []string{"/products/:id"},
+		Coalesce: true,
+	}
+	server := echo4.New()
+	server.Use(MiddlewareV4(conf, newMemoryCache()))
+	server.GET("/products/:id", func(ctx echo4.Context) error {
+		atomic.AddInt32(&calls, 1)
+		entered <- struct{}{}
+		<-release
+		ctx.Response().Header().Set("Vary", "Accept-Language")
+		return ctx.String(http.StatusOK, "lang="+ctx.Request().Header.Get("Accept-Language"))
+	})
+
+	var wg sync.WaitGroup
+	bodies := map[string]*httptest.ResponseRecorder{"en": httptest.NewRecorder(), "th": httptest.NewRecorder()}
+	request := func(language string) {
+		defer wg.Done()
+		req := httptest.NewRequest(http.MethodGet, "/products/1", nil)
+		req.Header.Set("Accept-Language", language)
+		server.ServeHTTP(bodies[language], req)
+	}
+	wg.Add(2)
+	go request("en")
+	<-entered
+	go request("th")
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, "lang=en", bodies["en"].Body.String())
+	assert.Equal(t, "lang=th", bodies["th"].Body.String())
+	assert.Equal(t, CacheMiss, bodies["th"].Header().Get("X-Cache"))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestMiddlewareV4WithCoalesceShouldCountWaitersAsHits(t *testing.T) {
+	metrics := NewPrometheusMetrics()
+	entered := make(chan struct{}, 1)
+	release := make(chan struct{})
+	conf := &Config{
+		Enabled:  true,
+		TTL:      "1m",
+		Paths:    []string{"/products/:id"},
+		Coalesce: true,
+		Metrics:  metrics,
+	}
+	server := echo4.New()
+	server.Use(MiddlewareV4(conf, newMemoryCache()))
+	server.GET("/products/:id", func(ctx echo4.Context) error {
+		entered <- struct{}{}
+		<-release
+		return ctx.String(http.StatusOK, "product")
+	})
+
+	var wg sync.WaitGroup
+	recorders := []*httptest.ResponseRecorder{httptest.NewRecorder(), httptest.NewRecorder()}
+	for index, rec := range recorders {
+		wg.Add(1)
+		go func(rec *httptest.ResponseRecorder) {
+			defer wg.Done()
+			server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/products/1", nil))
+		}(rec)
+		if index == 0 {
+			<-entered
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, CacheMiss, recorders[0].Header().Get("X-Cache"))
+	assert.Equal(t, CacheHit, recorders[1].Header().Get("X-Cache"))
+	assert.Equal(t, uint64(1), metrics.Counter(MetricMisses, "/products/:id"))
+	assert.Equal(t, uint64(1), metrics.Counter(MetricHits, "/products/:id"))
+}
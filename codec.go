@@ -0,0 +1,204 @@
+package cacheman
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+)
+
+// Codec encodes content to be stored in cache and decodes it back
+type Codec interface {
+	Encode(content *Content) ([]byte, error)
+	Decode(data []byte, content *Content) error
+}
+
+const (
+	// binaryMagic is the first byte of content encoded by BinaryCodec. JSON content never starts with it.
+	binaryMagic byte = 0xCA
+	// binaryVersion is the version of binary format written by BinaryCodec
+	binaryVersion byte = 1
+)
+
+// ErrInvalidContent is returned when cached data cannot be decoded into content
+var ErrInvalidContent = errors.New("invalid cached content")
+
+// JSONCodec encodes content as JSON with base64 body. It decodes binary content too.
+type JSONCodec struct{}
+
+// Encode encodes content as JSON
+func (JSONCodec) Encode(content *Content) ([]byte, error) {
+	return json.Marshal(content)
+}
+
+// Decode decodes JSON or binary content
+func (JSONCodec) Decode(data []byte, content *Content) error {
+	if isBinaryContent(data) {
+		return decodeBinary(data, content)
+	}
+	return json.Unmarshal(data, content)
+}
+
+// BinaryCodec encodes content in compact binary format with length-prefixed headers and raw body.
+// It decodes JSON content too, so entries written by JSONCodec are still readable.
+type BinaryCodec struct{}
+
+// Encode encodes content in binary format
+func (BinaryCodec) Encode(content *Content) ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.Grow(len(content.Content) + 256)
+	buffer.WriteByte(binaryMagic)
+	buffer.WriteByte(binaryVersion)
+	writeUvarint(&buffer, uint64(content.Status))
+	var flags byte
+	if content.Negative {
+		flags |= 1
+	}
+	buffer.WriteByte(flags)
+	writeVarint(&buffer, content.FreshUntil)
+	writeVarint(&buffer, content.StoredAt)
+	writeString(&buffer, content.ETag)
+	writeString(&buffer, content.LastModified)
+	writeString(&buffer, content.Encoding)
+
+	names := make([]string, 0, len(content.Headers))
+	for name := range content.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	writeUvarint(&buffer, uint64(len(names)))
+	for _, name := range names {
+		writeString(&buffer, name)
+		values := content.Headers[name]
+		writeUvarint(&buffer, uint64(len(values)))
+		for _, value := range values {
+			writeString(&buffer, value)
+		}
+	}
+
+	writeUvarint(&buffer, uint64(len(content.Content)))
+	buffer.Write(content.Content)
+	return buffer.Bytes(), nil
+}
+
+// Decode decodes binary or JSON content
+func (BinaryCodec) Decode(data []byte, content *Content) error {
+	if !isBinaryContent(data) {
+		return json.Unmarshal(data, content)
+	}
+	return decodeBinary(data, content)
+}
+
+// isBinaryContent returns true if data is encoded by BinaryCodec
+func isBinaryContent(data []byte) bool {
+	return len(data) > 0 && data[0] == binaryMagic
+}
+
+func decodeBinary(data []byte, content *Content) error {
+	if len(data) < 2 || data[1] != binaryVersion {
+		return ErrInvalidContent
+	}
+	reader := &binaryReader{data: data[2:]}
+	decoded := Content{}
+	decoded.Status = int(reader.uvarint())
+	flags := reader.byte()
+	decoded.Negative = flags&1 != 0
+	decoded.FreshUntil = reader.varint()
+	decoded.StoredAt = reader.varint()
+	decoded.ETag = reader.string()
+	decoded.LastModified = reader.string()
+	decoded.Encoding = reader.string()
+
+	count := reader.uvarint()
+	decoded.Headers = http.Header{}
+	for index := uint64(0); index < count && reader.err == nil; index++ {
+		name := reader.string()
+		valueCount := reader.uvarint()
+		values := []string{}
+		for valueIndex := uint64(0); valueIndex < valueCount && reader.err == nil; valueIndex++ {
+			values = append(values, reader.string())
+		}
+		decoded.Headers[name] = values
+	}
+
+	decoded.Content = reader.bytes()
+	if reader.err != nil {
+		return reader.err
+	}
+	*content = decoded
+	return nil
+}
+
+func writeUvarint(buffer *bytes.Buffer, value uint64) {
+	var scratch [binary.MaxVarintLen64]byte
+	buffer.Write(scratch[:binary.PutUvarint(scratch[:], value)])
+}
+
+func writeVarint(buffer *bytes.Buffer, value int64) {
+	var scratch [binary.MaxVarintLen64]byte
+	buffer.Write(scratch[:binary.PutVarint(scratch[:], value)])
+}
+
+func writeString(buffer *bytes.Buffer, value string) {
+	writeUvarint(buffer, uint64(len(value)))
+	buffer.WriteString(value)
+}
+
+// binaryReader reads binary content, it keeps the first error and returns zero values after it
+type binaryReader struct {
+	data []byte
+	err  error
+}
+
+func (r *binaryReader) byte() byte {
+	if r.err != nil || len(r.data) < 1 {
+		r.err = ErrInvalidContent
+		return 0
+	}
+	value := r.data[0]
+	r.data = r.data[1:]
+	return value
+}
+
+func (r *binaryReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	value, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = ErrInvalidContent
+		return 0
+	}
+	r.data = r.data[n:]
+	return value
+}
+
+func (r *binaryReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	value, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.err = ErrInvalidContent
+		return 0
+	}
+	r.data = r.data[n:]
+	return value
+}
+
+func (r *binaryReader) bytes() []byte {
+	length := r.uvarint()
+	if r.err != nil || uint64(len(r.data)) < length {
+		r.err = ErrInvalidContent
+		return nil
+	}
+	value := r.data[:length:length]
+	r.data = r.data[length:]
+	return value
+}
+
+func (r *binaryReader) string() string {
+	return string(r.bytes())
+}
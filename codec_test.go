@@ -0,0 +1,61 @@
+package cacheman
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newCodecContent() Content {
+	content := NewContent(http.StatusNotFound, http.Header{
+		"Content-Type": []string{"application/json"},
+		"Set-Cookie":   []string{"a=1", "b=2"},
+	}, []byte(`{"message":"not found"}`))
+	content.FreshUntil = 1600000000000000000
+	content.StoredAt = 1500000000000000000
+	content.Negative = true
+	content.Encoding = CompressionGzip
+	return content
+}
+
+func TestBinaryCodecShouldRoundTripContent(t *testing.T) {
+	content := newCodecContent()
+
+	data, e := BinaryCodec{}.Encode(&content)
+	assert.NoError(t, e)
+	assert.Equal(t, binaryMagic, data[0])
+
+	var decoded Content
+	assert.NoError(t, BinaryCodec{}.Decode(data, &decoded))
+	assert.Equal(t, content, decoded)
+}
+
+func TestBinaryCodecShouldDecodeJSONContent(t *testing.T) {
+	content := newCodecContent()
+	data, e := JSONCodec{}.Encode(&content)
+	assert.NoError(t, e)
+
+	var decoded Content
+	assert.NoError(t, BinaryCodec{}.Decode(data, &decoded))
+	assert.Equal(t, content, decoded)
+}
+
+func TestJSONCodecShouldDecodeBinaryContent(t *testing.T) {
+	content := newCodecContent()
+	data, e := BinaryCodec{}.Encode(&content)
+	assert.NoError(t, e)
+
+	var decoded Content
+	assert.NoError(t, JSONCodec{}.Decode(data, &decoded))
+	assert.Equal(t, content, decoded)
+}
+
+func TestBinaryCodecShouldRejectTruncatedContent(t *testing.T) {
+	content := newCodecContent()
+	data, _ := BinaryCodec{}.Encode(&content)
+
+	var decoded Content
+	assert.Equal(t, ErrInvalidContent, BinaryCodec{}.Decode(data[:len(data)-1], &decoded))
+	assert.Equal(t, ErrInvalidContent, BinaryCodec{}.Decode([]byte{binaryMagic, 99}, &decoded))
+}
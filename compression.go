@@ -0,0 +1,146 @@
+package cacheman
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/golang/snappy"
+)
+
+const (
+	// CompressionGzip compresses cached content with gzip, it is served as is to clients accepting gzip
+	CompressionGzip = "gzip"
+	// CompressionSnappy compresses cached content with snappy, it is always decompressed before serving
+	CompressionSnappy = "snappy"
+)
+
+// minCompressSize is the smallest body in bytes worth compressing
+const minCompressSize = 256
+
+// parseCompression returns supported compression name, or empty string for no compression
+func parseCompression(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case CompressionGzip, CompressionSnappy:
+		return name
+	}
+	return ""
+}
+
+// compressBody compresses body with encoding
+func compressBody(encoding string, body []byte) ([]byte, error) {
+	switch encoding {
+	case CompressionGzip:
+		var buffer bytes.Buffer
+		writer := gzip.NewWriter(&buffer)
+		if _, e := writer.Write(body); e != nil {
+			return nil, e
+		}
+		if e := writer.Close(); e != nil {
+			return nil, e
+		}
+		return buffer.Bytes(), nil
+	case CompressionSnappy:
+		return snappy.Encode(nil, body), nil
+	}
+	return nil, fmt.Errorf("unsupported compression: %s", encoding)
+}
+
+// decompressBody decompresses body compressed with encoding
+func decompressBody(encoding string, body []byte) ([]byte, error) {
+	switch encoding {
+	case CompressionGzip:
+		reader, e := gzip.NewReader(bytes.NewReader(body))
+		if e != nil {
+			return nil, e
+		}
+		defer reader.Close()
+		return ioutil.ReadAll(reader)
+	case CompressionSnappy:
+		return snappy.Decode(nil, body)
+	}
+	return nil, fmt.Errorf("unsupported compression: %s", encoding)
+}
+
+// acceptsEncoding returns true if Accept-Encoding of request allows encoding
+func acceptsEncoding(req *http.Request, encoding string) bool {
+	accepted := false
+	for _, value := range req.Header.Values("Accept-Encoding") {
+		for _, item := range strings.Split(value, ",") {
+			parts := strings.Split(item, ";")
+			name := strings.ToLower(strings.TrimSpace(parts[0]))
+			if name != encoding && name != "*" {
+				continue
+			}
+			quality := 1.0
+			for _, param := range parts[1:] {
+				param = strings.TrimSpace(param)
+				if strings.HasPrefix(param, "q=") {
+					if q, e := strconv.ParseFloat(param[2:], 64); e == nil {
+						quality = q
+					}
+				}
+			}
+			// Explicit encoding takes precedence over wildcard
+			if name == encoding {
+				return quality > 0
+			}
+			accepted = quality > 0
+		}
+	}
+	return accepted
+}
+
+// compress compresses content body with configured compression.
+// Body is kept as is if it is small, already encoded by handler or does not shrink.
+func (c *Manager) compress(content *Content, body []byte) {
+	if c.Compression == "" || len(body) < minCompressSize || content.Headers.Get("Content-Encoding") != "" {
+		return
+	}
+	compressed, e := compressBody(c.Compression, body)
+	if e != nil || len(compressed) >= len(body) {
+		return
+	}
+	content.Content = compressed
+	content.Encoding = c.Compression
+}
+
+// responseBody returns content body to be sent for request, and content encoding if body is sent compressed.
+// Gzip body is sent as is to clients accepting gzip, otherwise it is decompressed.
+func responseBody(req *http.Request, content *Content) ([]byte, string, error) {
+	if content.Encoding == "" {
+		return content.Content, "", nil
+	}
+	if content.Encoding == CompressionGzip && acceptsEncoding(req, CompressionGzip) {
+		return content.Content, CompressionGzip, nil
+	}
+	body, e := decompressBody(content.Encoding, content.Content)
+	return body, "", e
+}
+
+// addVary adds header name into Vary header if it is not there yet
+func addVary(header http.Header, name string) {
+	names, varyAll := parseVary(header)
+	if varyAll {
+		return
+	}
+	for _, existing := range names {
+		if existing == http.CanonicalHeaderKey(name) {
+			return
+		}
+	}
+	header.Add("Vary", name)
+}
+
+// weakETag returns weak form of etag
+func weakETag(etag string) string {
+	if etag == "" || strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return "W/" + etag
+}
@@ -0,0 +1,130 @@
+package cacheman
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	echo4 "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+var compressibleBody = strings.Repeat("compressible product description ", 100)
+
+func TestParseCompressionShouldIgnoreUnsupportedCompression(t *testing.T) {
+	assert.Equal(t, CompressionGzip, parseCompression(" GZIP "))
+	assert.Equal(t, CompressionSnappy, parseCompression("snappy"))
+	assert.Equal(t, "", parseCompression("brotli"))
+}
+
+func TestCompressBodyShouldRoundTrip(t *testing.T) {
+	for _, encoding := range []string{CompressionGzip, CompressionSnappy} {
+		compressed, e := compressBody(encoding, []byte(compressibleBody))
+		assert.NoError(t, e)
+		assert.Less(t, len(compressed), len(compressibleBody))
+
+		body, e := decompressBody(encoding, compressed)
+		assert.NoError(t, e)
+		assert.Equal(t, compressibleBody, string(body))
+	}
+}
+
+func TestAcceptsEncodingShouldHonourQuality(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	assert.False(t, acceptsEncoding(req, CompressionGzip))
+
+	req.Header.Set("Accept-Encoding", "deflate, gzip;q=0.5")
+	assert.True(t, acceptsEncoding(req, CompressionGzip))
+
+	req.Header.Set("Accept-Encoding", "gzip;q=0, *")
+	assert.False(t, acceptsEncoding(req, CompressionGzip))
+
+	req.Header.Set("Accept-Encoding", "*")
+	assert.True(t, acceptsEncoding(req, CompressionGzip))
+}
+
+func TestMiddlewareV4ShouldStoreCompressedContent(t *testing.T) {
+	cache := newMemoryCache()
+	server := newEchoV4Server(&Config{
+		Enabled:     true,
+		TTL:         "1m",
+		Paths:       []string{"/products/:id"},
+		Compression: CompressionSnappy,
+	}, cache, func(ctx echo4.Context) error {
+		return ctx.String(http.StatusOK, compressibleBody)
+	})
+
+	serveRequest(server, http.MethodGet, "/products/1")
+
+	var content Content
+	assert.NoError(t, BinaryCodec{}.Decode(cache.entries["/products/1"], &content))
+	assert.Equal(t, CompressionSnappy, content.Encoding)
+	assert.Less(t, len(content.Content), len(compressibleBody))
+}
+
+func TestMiddlewareV4ShouldServeGzipContentToClientAcceptingGzip(t *testing.T) {
+	calls := 0
+	server := newEchoV4Server(&Config{
+		Enabled:     true,
+		TTL:         "1m",
+		Paths:       []string{"/products/:id"},
+		Compression: CompressionGzip,
+	}, newMemoryCache(), func(ctx echo4.Context) error {
+		calls++
+		return ctx.String(http.StatusOK, compressibleBody)
+	})
+	serveRequest(server, http.MethodGet, "/products/1")
+
+	req := httptest.NewRequest(http.MethodGet, "/products/1", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+	assert.True(t, strings.HasPrefix(rec.Header().Get("ETag"), "W/"))
+	body, e := decompressBody(CompressionGzip, rec.Body.Bytes())
+	assert.NoError(t, e)
+	assert.Equal(t, compressibleBody, string(body))
+}
+
+func TestMiddlewareV4ShouldDecompressContentForClientNotAcceptingGzip(t *testing.T) {
+	calls := 0
+	server := newEchoV4Server(&Config{
+		Enabled:     true,
+		TTL:         "1m",
+		Paths:       []string{"/products/:id"},
+		Compression: CompressionGzip,
+	}, newMemoryCache(), func(ctx echo4.Context) error {
+		calls++
+		return ctx.String(http.StatusOK, compressibleBody)
+	})
+	serveRequest(server, http.MethodGet, "/products/1")
+
+	rec := serveRequest(server, http.MethodGet, "/products/1")
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, "", rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+	assert.Equal(t, compressibleBody, rec.Body.String())
+}
+
+func TestMiddlewareV4ShouldNotCompressSmallContent(t *testing.T) {
+	cache := newMemoryCache()
+	server := newEchoV4Server(&Config{
+		Enabled:     true,
+		TTL:         "1m",
+		Paths:       []string{"/products/:id"},
+		Compression: CompressionGzip,
+	}, cache, func(ctx echo4.Context) error {
+		return ctx.String(http.StatusOK, "small")
+	})
+
+	serveRequest(server, http.MethodGet, "/products/1")
+
+	var content Content
+	assert.NoError(t, BinaryCodec{}.Decode(cache.entries["/products/1"], &content))
+	assert.Equal(t, "", content.Encoding)
+}
@@ -0,0 +1,52 @@
+package cacheman
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// generateETag creates strong entity tag from content body
+func generateETag(body []byte) string {
+	return fmt.Sprintf(`"%x"`, sha1.Sum(body))
+}
+
+// isNotModified returns true if conditional request can be answered with 304 Not Modified.
+// If-Modified-Since is evaluated only when If-None-Match is absent.
+func isNotModified(req *http.Request, etag, lastModified string) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	if ifNoneMatch := req.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return etagMatches(ifNoneMatch, etag)
+	}
+	ifModifiedSince := req.Header.Get("If-Modified-Since")
+	if ifModifiedSince == "" || lastModified == "" {
+		return false
+	}
+	since, e := http.ParseTime(ifModifiedSince)
+	if e != nil {
+		return false
+	}
+	modified, e := http.ParseTime(lastModified)
+	if e != nil {
+		return false
+	}
+	return !modified.After(since)
+}
+
+// etagMatches weakly compares entity tag against If-None-Match header value
+func etagMatches(ifNoneMatch, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
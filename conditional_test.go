@@ -0,0 +1,47 @@
+package cacheman
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEtagMatchesShouldCompareWeakly(t *testing.T) {
+	assert.True(t, etagMatches(`W/"abc"`, `"abc"`))
+	assert.True(t, etagMatches(`"xyz", "abc"`, `"abc"`))
+	assert.True(t, etagMatches(`*`, `"abc"`))
+	assert.False(t, etagMatches(`"xyz"`, `"abc"`))
+	assert.False(t, etagMatches(`*`, ``))
+}
+
+func TestIsNotModifiedWithMatchingIfNoneMatch(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", `"abc"`)
+
+	assert.True(t, isNotModified(req, `"abc"`, ""))
+}
+
+func TestIsNotModifiedShouldIgnoreIfModifiedSinceWhenIfNoneMatchIsPresent(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", `"xyz"`)
+	req.Header.Set("If-Modified-Since", "Wed, 21 Oct 2015 07:28:00 GMT")
+
+	assert.False(t, isNotModified(req, `"abc"`, "Wed, 21 Oct 2015 07:28:00 GMT"))
+}
+
+func TestIsNotModifiedWithIfModifiedSince(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-Modified-Since", "Wed, 21 Oct 2015 07:28:00 GMT")
+
+	assert.True(t, isNotModified(req, `"abc"`, "Wed, 21 Oct 2015 07:28:00 GMT"))
+	assert.False(t, isNotModified(req, `"abc"`, "Thu, 22 Oct 2015 07:28:00 GMT"))
+}
+
+func TestIsNotModifiedShouldIgnoreUnsafeMethod(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("If-None-Match", `"abc"`)
+
+	assert.False(t, isNotModified(req, `"abc"`, ""))
+}
@@ -10,10 +10,63 @@ type Config struct {
 	TTL string
 	// Paths that will be cached
 	Paths []string
+	// Rules are paths to be cached with their own options, e.g. TTL. Rules have higher priority than Paths.
+	Rules []Rule
 	// ExcludedPaths are paths to be excluded from cache
 	ExcludedPaths []string
 	// AdditionalHeaders are injected in return cache
 	AdditionalHeaders map[string]string
+	// Policy is either "force" to cache response regardless of Cache-Control headers or
+	// "respect" to follow Cache-Control headers of request and response. Default is "force".
+	Policy string
+	// VaryHeaders are request headers to be part of cache key, in addition to Vary header of response
+	VaryHeaders []string
+	// KeyFunc builds cache key from request. Default is RequestURIKey.
+	KeyFunc KeyFunc
+	// EchoKeyFunc builds cache key from echo V4 context instead of KeyFunc, e.g. from claims set by JWT middleware.
+	// Requests are not cached by other middlewares if it is set.
+	EchoKeyFunc EchoKeyFunc
+	// StaleWhileRevalidate is duration after TTL in which stale entry is served while it is refreshed in background.
+	// It is ignored by gin middleware.
+	StaleWhileRevalidate string
+	// StaleIfError is duration after TTL in which stale entry is served if handler fails
+	StaleIfError string
+	// Coalesce lets only one of concurrent requests of the same cache key run handler on cache miss,
+	// the others wait for its response
+	Coalesce bool
+	// CoalesceTimeout is how long a request waits for the running handler before running it itself. Default is 5s.
+	CoalesceTimeout string
+	// MaxBodySize is the largest response body in bytes to be cached, larger response is passed through. Zero means no limit.
+	MaxBodySize int
+	// Compression compresses cached content body, either "gzip" or "snappy". Gzip content is served as is
+	// to clients accepting gzip. Default is no compression.
+	Compression string
+	// Codec encodes content stored in cache. Default is BinaryCodec, JSONCodec keeps the old JSON format.
+	// Both of them read content written in either format.
+	Codec Codec
+	// Metrics collects cache metrics, e.g. NewPrometheusMetrics(). Default is no metrics.
+	Metrics MetricsCollector
+	// MetricsPath is URI to request metrics if Metrics is also an http.Handler, like PrometheusMetrics
+	MetricsPath string
+	// ExposeCacheKey adds X-Cache-Key header with cache key of response, for debugging
+	ExposeCacheKey bool
+	// CacheStatusName is name of cache in RFC 9211 Cache-Status header, e.g. "cacheman".
+	// Cache-Status header is added only if it is not empty.
+	CacheStatusName string
+	// Logger logs cache activity and errors, e.g. NewSlogLogger(nil) or NewEchoLogger(e.Logger).
+	// Default logs everything to stdout if Verbose is true, or nothing otherwise.
+	Logger Logger
+	// CacheableStatuses are response statuses to be cached with their TTL, empty TTL means TTL of route.
+	// Default is 200 only.
+	CacheableStatuses map[int]string
+	// CacheableMethods are request methods to be cached. HEAD is served from content of GET,
+	// POST is cached by hash of its body. Default is GET only.
+	CacheableMethods []string
+	// NegativeStatuses are failure statuses to be cached, e.g. 404 or 410, with their TTL. Empty TTL means NegativeTTL.
+	// Echo HTTPError of these statuses returned by handler is rendered and cached too.
+	NegativeStatuses map[int]string
+	// NegativeTTL is default TTL of NegativeStatuses. Default is 10s.
+	NegativeTTL string
 	// Server is cache server in host:port format
 	Server string
 	// Password is credential for accessing cache service
@@ -22,8 +75,27 @@ type Config struct {
 	Database interface{}
 	// CacheInfoPath is URI to request cache information
 	CacheInfoPath string
-	// PurgePath is URI to purge all content in cache
+	// PurgePath is URI to purge all content in cache. PURGE request to other URI purges that URI only.
+	// Purge is disabled if it is empty.
 	PurgePath string
+	// AdminAuth authorizes requests to PurgePath, CacheInfoPath and MetricsPath. Default allows everyone.
+	AdminAuth *AdminAuth
 	// Namespace to be automatically added into cache key
 	Namespace string
 }
+
+// Rule is per-route cache rule
+type Rule struct {
+	// Path to be cached, same format as Paths
+	Path string
+	// TTL is age of cache entry of this path, Config.TTL is used if it is empty
+	TTL string
+	// AdditionalHeaders are injected in return cache of this path, in addition to Config.AdditionalHeaders
+	AdditionalHeaders map[string]string
+	// StaleWhileRevalidate overrides Config.StaleWhileRevalidate for this path
+	StaleWhileRevalidate string
+	// StaleIfError overrides Config.StaleIfError for this path
+	StaleIfError string
+	// Tags are attached to cached content of this path, to be purged by tag
+	Tags []string
+}
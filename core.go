@@ -0,0 +1,160 @@
+package cacheman
+
+import (
+	"net/http"
+	"time"
+)
+
+// handlerFunc runs next handler of framework middleware chain, writing response into writer.
+// Returned error is error of handler which is not written into response.
+type handlerFunc func(writer http.ResponseWriter, req *http.Request) error
+
+// backgroundFunc prepares next handler to run in background, writing response into writer, e.g. to refresh stale content.
+// It is called while request is still being served, so framework adapter can copy what it needs from its context.
+type backgroundFunc func(writer http.ResponseWriter, req *http.Request) func() error
+
+// serve serves request from cache, or by next handler and stores its response, and serves admin requests.
+// It returns false if request is not handled by cache, then framework adapter runs its next handler as is.
+// Background may be nil if framework adapter cannot run handler in background, then stale content is not served while revalidating.
+// Authorize is additional authorization of admin request given by framework adapter, it may be nil.
+func (c *Manager) serve(writer http.ResponseWriter, req *http.Request, next handlerFunc, background backgroundFunc, authorize func() bool) (bool, error) {
+	if !c.Enabled {
+		return false, nil
+	}
+	c.Logger.Debug("Cache tests path", "uri", req.RequestURI)
+	if req.Method == "GET" && enabledByPath(c.CacheInfoPath, req.URL.Path) {
+		c.Logger.Debug("Cache info request", "uri", req.RequestURI)
+		if c.authorizeAdmin(writer, req, authorize) {
+			c.WriteInfo(writer, req)
+		}
+		return true, nil
+	}
+	if req.Method == "GET" && enabledByPath(c.MetricsPath, req.URL.Path) {
+		if handler, ok := c.Metrics.(http.Handler); ok {
+			if c.authorizeAdmin(writer, req, authorize) {
+				handler.ServeHTTP(writer, req)
+			}
+			return true, nil
+		}
+	}
+	if !c.CacheableMethods[req.Method] {
+		if req.Method == "PURGE" && c.PurgePath != "" {
+			if c.authorizeAdmin(writer, req, authorize) {
+				c.ServePurge(writer, req)
+			}
+			return true, nil
+		}
+		c.Logger.Debug("Method does not match", "uri", req.RequestURI, "method", req.Method)
+		return false, nil
+	}
+	rule, matched := c.MatchRule(req.URL.Path)
+	if !matched || req.Header.Get("Upgrade") != "" {
+		c.Logger.Debug("Path does not match", "uri", req.RequestURI)
+		return false, nil
+	}
+	if !c.keyable(req) {
+		c.Logger.Debug("Cache key needs echo V4 context", "uri", req.RequestURI)
+		return false, nil
+	}
+
+	route := rule.Rule.Path
+	c.Logger.Debug("Path matches", "uri", req.RequestURI, "route", route)
+
+	lookup := c.Policy.CanLookup(req)
+	if !lookup {
+		c.Logger.Debug("Cache bypasses", "uri", req.RequestURI, "route", route)
+		c.count(MetricBypasses, route)
+	}
+	var content *Content
+	var cacheKey string
+	found := false
+	if lookup {
+		content, cacheKey, found = c.lookup(req, route)
+	}
+
+	now := time.Now()
+	if found && (content.Fresh(now) || (background != nil && revalidatable(content, rule, now))) {
+		status := CacheHit
+		if !content.Fresh(now) {
+			c.Logger.Debug("Cache serves stale", "key", cacheKey, "route", route)
+			c.refresh(req, background, rule, cacheKey)
+			status = CacheStale
+		}
+		c.writeCacheStatus(writer.Header(), status, cacheKey, content)
+		if c.writeContent(writer, req, content) {
+			c.countContent(MetricHits, MetricNegativeHits, content, route)
+			return true, nil
+		}
+	}
+
+	if found && errorServable(content, rule, now) {
+		// Hold response back, stale content is served instead if handler fails
+		interceptor := NewBufferedInterceptor(writer)
+		interceptor.SetMaxBodySize(c.MaxBodySize)
+		c.writeCacheStatus(writer.Header(), CacheMiss, cacheKey, content)
+		e := next(interceptor, req)
+		// Stale content can replace response only if nothing has been sent yet
+		if failed(e, interceptor.Status()) && interceptor.buffered {
+			c.Logger.Warn("Cache serves stale on error", "key", cacheKey, "route", route, "status", interceptor.Status(), "error", e)
+			for headerKey := range writer.Header() {
+				writer.Header().Del(headerKey)
+			}
+			c.writeCacheStatus(writer.Header(), CacheStale, cacheKey, content)
+			if c.writeContent(writer, req, content) {
+				c.countContent(MetricHits, MetricNegativeHits, content, route)
+				return true, nil
+			}
+		}
+		c.count(MetricMisses, route)
+		if e == nil {
+			c.store(req, rule, interceptor)
+		}
+		interceptor.Commit()
+		return true, e
+	}
+
+	var waiting *flight
+	leader := false
+	if lookup && c.Coalesce {
+		waiting, leader = c.flights.join(cacheKey)
+		if !leader {
+			c.Logger.Debug("Cache waits", "key", cacheKey, "route", route)
+			// Content of leader is served only if it is the variant this request selects
+			if stored, storedVariant, ok := waiting.wait(c.CoalesceTimeout); ok && storedVariant.matches(c.requestKey(req), req) {
+				c.writeCacheStatus(writer.Header(), CacheHit, storedVariant.key, stored)
+				if c.writeContent(writer, req, stored) {
+					c.countContent(MetricHits, MetricNegativeHits, stored, route)
+					return true, nil
+				}
+				for _, name := range cacheStatusHeaders {
+					writer.Header().Del(name)
+				}
+			}
+		}
+	}
+
+	if lookup {
+		c.count(MetricMisses, route)
+		c.writeCacheStatus(writer.Header(), CacheMiss, cacheKey, content)
+	} else {
+		c.writeCacheStatus(writer.Header(), CacheBypass, "", nil)
+	}
+	interceptor := NewInterceptor(writer)
+	interceptor.SetMaxBodySize(c.MaxBodySize)
+	var stored *Content
+	var storedVariant variant
+	if leader {
+		defer func() {
+			c.flights.finish(cacheKey, waiting, stored, storedVariant)
+		}()
+	}
+	e := next(interceptor, req)
+	if e == nil {
+		stored, storedVariant = c.store(req, rule, interceptor)
+	}
+	return true, e
+}
+
+func enabledByPath(expectedPath, actualPath string) bool {
+	return expectedPath != "" && expectedPath == actualPath
+}
@@ -0,0 +1,130 @@
+package cacheman
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// grpcMessageHeader is header of cached content keeping full name of response message type
+const grpcMessageHeader = "Grpc-Message-Type"
+
+// UnaryServerInterceptor creates a gRPC unary server interceptor to handle cache.
+// Full method names, e.g. /grpc.health.v1.Health/Check, are matched against Paths and Rules.
+func UnaryServerInterceptor(config *Config, cache CacheInterface) grpc.UnaryServerInterceptor {
+	return UnaryServerInterceptorWithManager(NewCacheManager(config, cache))
+}
+
+// UnaryServerInterceptorWithManager creates a gRPC unary server interceptor to handle cache with an existing cache manager
+func UnaryServerInterceptorWithManager(cm *Manager) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !cm.Enabled {
+			return handler(ctx, req)
+		}
+		message, ok := req.(proto.Message)
+		rule, matched := cm.MatchRule(info.FullMethod)
+		if !ok || !matched {
+			cm.Logger.Debug("Method does not match", "method", info.FullMethod)
+			return handler(ctx, req)
+		}
+		route := rule.Rule.Path
+		cacheKey, e := grpcKey(info.FullMethod, message)
+		if e != nil {
+			cm.Logger.Warn("Cache fails to marshal request", "method", info.FullMethod, "route", route, "error", e)
+			cm.count(MetricBypasses, route)
+			return handler(ctx, req)
+		}
+
+		if response, found := cm.lookupGRPC(cacheKey, route); found {
+			cm.count(MetricHits, route)
+			return response, nil
+		}
+		cm.count(MetricMisses, route)
+		response, e := handler(ctx, req)
+		if e == nil {
+			if message, ok := response.(proto.Message); ok {
+				cm.storeGRPC(cacheKey, rule, message)
+			}
+		}
+		return response, e
+	}
+}
+
+// grpcKey returns cache key of gRPC request: method name and hash of deterministically marshalled request
+func grpcKey(method string, req proto.Message) (string, error) {
+	body, e := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if e != nil {
+		return "", e
+	}
+	return fmt.Sprintf("%s#proto=%x", method, sha256.Sum256(body)), nil
+}
+
+// lookupGRPC gets fresh cached response of gRPC request
+func (c *Manager) lookupGRPC(cacheKey, route string) (proto.Message, bool) {
+	b, found := c.get(cacheKey, route)
+	if !found {
+		return nil, false
+	}
+	var content Content
+	if e := c.Codec.Decode(b, &content); e != nil {
+		c.Logger.Warn("Cache fails to decode", "key", cacheKey, "route", route, "error", e)
+		c.count(MetricDecodeErrors, route)
+		return nil, false
+	}
+	if !content.Fresh(time.Now()) {
+		return nil, false
+	}
+	messageType, e := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(content.Headers.Get(grpcMessageHeader)))
+	if e != nil {
+		c.Logger.Warn("Cache fails to find response type", "key", cacheKey, "route", route, "error", e)
+		return nil, false
+	}
+	response := messageType.New().Interface()
+	if e := proto.Unmarshal(content.Content, response); e != nil {
+		c.Logger.Warn("Cache fails to unmarshal response", "key", cacheKey, "route", route, "error", e)
+		c.count(MetricDecodeErrors, route)
+		return nil, false
+	}
+	return response, true
+}
+
+// storeGRPC stores response of gRPC request with TTL and tags of matched rule
+func (c *Manager) storeGRPC(cacheKey string, rule *ComparableRule, response proto.Message) {
+	route := rule.Rule.Path
+	body, e := proto.Marshal(response)
+	if e != nil {
+		c.Logger.Error("Cache fails to marshal response", "key", cacheKey, "route", route, "error", e)
+		c.count(MetricStoreFailures, route)
+		return
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/x-protobuf")
+	header.Set(grpcMessageHeader, string(response.ProtoReflect().Descriptor().FullName()))
+	content := NewContent(http.StatusOK, header, body)
+	now := time.Now()
+	content.StoredAt = now.UnixNano()
+	content.FreshUntil = now.Add(rule.TTL).UnixNano()
+	stringifiedCache, e := c.Codec.Encode(&content)
+	if e != nil {
+		c.Logger.Error("Cache fails to encode", "key", cacheKey, "route", route, "error", e)
+		c.count(MetricStoreFailures, route)
+		return
+	}
+	// Stale response is never served to gRPC, so it is stored for TTL only
+	storeTTL := rule.TTL
+	if e := c.setWithTTL(cacheKey, stringifiedCache, storeTTL, route); e != nil {
+		c.count(MetricStoreFailures, route)
+		return
+	}
+	c.count(MetricStores, route)
+	if e := c.tag(cacheKey, rule.Rule.Tags, storeTTL); e != nil {
+		c.Logger.Error("Cache fails to tag", "key", cacheKey, "route", route, "error", e)
+	}
+}
@@ -0,0 +1,104 @@
+package cacheman
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newHealthClient(t *testing.T, cm *Manager) (healthpb.HealthClient, *health.Server) {
+	listener := bufconn.Listen(1024 * 1024)
+	healthServer := health.NewServer()
+	server := grpc.NewServer(grpc.UnaryInterceptor(UnaryServerInterceptorWithManager(cm)))
+	healthpb.RegisterHealthServer(server, healthServer)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, e := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, address string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.NoError(t, e)
+	t.Cleanup(func() { conn.Close() })
+	return healthpb.NewHealthClient(conn), healthServer
+}
+
+func checkHealth(t *testing.T, client healthpb.HealthClient, service string) healthpb.HealthCheckResponse_ServingStatus {
+	response, e := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	assert.NoError(t, e)
+	return response.GetStatus()
+}
+
+func TestUnaryServerInterceptorShouldServeCachedResponse(t *testing.T) {
+	cm := NewCacheManager(&Config{
+		Enabled:   true,
+		TTL:       "1m",
+		Namespace: "grpc",
+		Paths:     []string{"/grpc.health.v1.Health/Check"},
+	}, newMemoryCache())
+	client, healthServer := newHealthClient(t, cm)
+	healthServer.SetServingStatus("shop", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("stock", healthpb.HealthCheckResponse_SERVING)
+
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, checkHealth(t, client, "shop"))
+	healthServer.SetServingStatus("shop", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthServer.SetServingStatus("stock", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, checkHealth(t, client, "shop"))
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, checkHealth(t, client, "stock"))
+	assert.Equal(t, uint64(1), *cm.stats.counters[MetricHits])
+	assert.Equal(t, uint64(2), *cm.stats.counters[MetricStores])
+}
+
+func TestUnaryServerInterceptorShouldBePurgedByMethod(t *testing.T) {
+	conf := &Config{
+		Enabled: true,
+		TTL:     "1m",
+		Paths:   []string{"/grpc.health.v1.Health/:method"},
+	}
+	cache, e := NewBigCache(conf)
+	assert.NoError(t, e)
+	cm := NewCacheManager(conf, cache)
+	client, healthServer := newHealthClient(t, cm)
+	healthServer.SetServingStatus("shop", healthpb.HealthCheckResponse_SERVING)
+	checkHealth(t, client, "shop")
+	healthServer.SetServingStatus("shop", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	assert.NoError(t, cm.PurgeKey("/grpc.health.v1.Health/Check"))
+
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, checkHealth(t, client, "shop"))
+}
+
+func TestUnaryServerInterceptorShouldSkipUnmatchedMethod(t *testing.T) {
+	cm := NewCacheManager(&Config{
+		Enabled: true,
+		TTL:     "1m",
+		Paths:   []string{"/grpc.health.v1.Health/Watch"},
+	}, newMemoryCache())
+	client, healthServer := newHealthClient(t, cm)
+	healthServer.SetServingStatus("shop", healthpb.HealthCheckResponse_SERVING)
+	checkHealth(t, client, "shop")
+	healthServer.SetServingStatus("shop", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, checkHealth(t, client, "shop"))
+}
+
+func TestGRPCKeyShouldBeDeterministic(t *testing.T) {
+	key, e := grpcKey("/grpc.health.v1.Health/Check", &healthpb.HealthCheckRequest{Service: "shop"})
+	assert.NoError(t, e)
+	sameKey, _ := grpcKey("/grpc.health.v1.Health/Check", &healthpb.HealthCheckRequest{Service: "shop"})
+	otherKey, _ := grpcKey("/grpc.health.v1.Health/Check", &healthpb.HealthCheckRequest{Service: "stock"})
+
+	assert.Equal(t, key, sameKey)
+	assert.NotEqual(t, key, otherKey)
+	assert.Regexp(t, `^/grpc\.health\.v1\.Health/Check#proto=[0-9a-f]{64}$`, key)
+}
@@ -0,0 +1,228 @@
+package cacheman
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Formats of cache information
+const (
+	// InfoFormatJSON renders cache information as JSON
+	InfoFormatJSON = "json"
+	// InfoFormatText renders cache information as sorted "name: value" lines
+	InfoFormatText = "text"
+)
+
+// latencyStats keeps count and total duration of a backend operation
+type latencyStats struct {
+	count uint64
+	total uint64
+	max   uint64
+}
+
+// managerStats keeps counters of manager since it is created, regardless of MetricsCollector
+type managerStats struct {
+	startedAt time.Time
+	counters  map[string]*uint64
+	latencies map[string]*latencyStats
+}
+
+func newManagerStats() *managerStats {
+	stats := &managerStats{
+		startedAt: time.Now(),
+		counters:  map[string]*uint64{},
+		latencies: map[string]*latencyStats{
+			OperationGet: {},
+			OperationSet: {},
+		},
+	}
+	for _, metric := range metricEvents {
+		stats.counters[metric.event] = new(uint64)
+	}
+	return stats
+}
+
+// count counts an event of route into manager counters and metrics
+func (c *Manager) count(event string, route string) {
+	if counter, ok := c.stats.counters[event]; ok {
+		atomic.AddUint64(counter, 1)
+	}
+	c.Metrics.Count(event, route)
+}
+
+// countContent counts event of content, and negativeEvent too if content is a cached failure status
+func (c *Manager) countContent(event, negativeEvent string, content *Content, route string) {
+	c.count(event, route)
+	if content.Negative {
+		c.count(negativeEvent, route)
+	}
+}
+
+// observe observes latency of a backend operation of route into manager counters and metrics
+func (c *Manager) observe(operation string, route string, duration time.Duration) {
+	if latency, ok := c.stats.latencies[operation]; ok {
+		atomic.AddUint64(&latency.count, 1)
+		atomic.AddUint64(&latency.total, uint64(duration))
+		for {
+			max := atomic.LoadUint64(&latency.max)
+			if uint64(duration) <= max || atomic.CompareAndSwapUint64(&latency.max, max, uint64(duration)) {
+				break
+			}
+		}
+	}
+	c.Metrics.ObserveBackend(operation, route, duration)
+}
+
+// info returns cache information: backend health and statistics, manager counters and effective configuration
+func (c *Manager) info() map[string]interface{} {
+	now := time.Now()
+	health := c.healthCheck()
+	counters := map[string]interface{}{}
+	for event, counter := range c.stats.counters {
+		counters[event] = atomic.LoadUint64(counter)
+	}
+	latencies := map[string]interface{}{}
+	for operation, latency := range c.stats.latencies {
+		count := atomic.LoadUint64(&latency.count)
+		average := time.Duration(0)
+		if count > 0 {
+			average = time.Duration(atomic.LoadUint64(&latency.total) / count)
+		}
+		latencies[operation] = map[string]interface{}{
+			"count":   count,
+			"average": average.String(),
+			"max":     time.Duration(atomic.LoadUint64(&latency.max)).String(),
+		}
+	}
+
+	info := map[string]interface{}{
+		"type":            c.Cache.Type(),
+		"operationHealth": health,
+		"startedAt":       c.stats.startedAt.UTC().Format(time.RFC3339),
+		"uptime":          now.Sub(c.stats.startedAt).Truncate(time.Second).String(),
+		"counters":        counters,
+		"backendLatency":  latencies,
+		"config":          c.configInfo(),
+	}
+	if reporter, ok := c.Cache.(StatsReporter); ok {
+		stats, e := reporter.Stats()
+		if e != nil {
+			c.Logger.Error("Cache fails to report stats", "error", e)
+			info["backendError"] = e.Error()
+		} else {
+			info["backend"] = stats
+		}
+	}
+	return info
+}
+
+// configInfo returns effective configuration. Credentials and server address are left out.
+func (c *Manager) configInfo() map[string]interface{} {
+	rules := []interface{}{}
+	for _, rule := range c.ComparableRules {
+		rules = append(rules, map[string]interface{}{
+			"path":                 rule.Rule.Path,
+			"ttl":                  rule.TTL.String(),
+			"staleWhileRevalidate": rule.StaleWhileRevalidate.String(),
+			"staleIfError":         rule.StaleIfError.String(),
+			"tags":                 stringList(rule.Rule.Tags),
+		})
+	}
+	methods := []string{}
+	for method := range c.CacheableMethods {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	statuses := map[string]interface{}{}
+	for status, ttl := range c.CacheableStatuses {
+		statuses[strconv.Itoa(status)] = ttl.String()
+	}
+	negativeStatuses := map[string]interface{}{}
+	for status, ttl := range c.NegativeStatuses {
+		negativeStatuses[strconv.Itoa(status)] = ttl.String()
+	}
+	return map[string]interface{}{
+		"enabled":              c.Enabled,
+		"namespace":            c.Namespace,
+		"ttl":                  c.TTL.String(),
+		"routes":               stringList(c.Routes),
+		"excludedRoutes":       stringList(c.ExcludedRoutes),
+		"rules":                rules,
+		"varyHeaders":          stringList(c.VaryHeaders),
+		"staleWhileRevalidate": c.StaleWhileRevalidate.String(),
+		"staleIfError":         c.StaleIfError.String(),
+		"coalesce":             c.Coalesce,
+		"maxBodySize":          c.MaxBodySize,
+		"compression":          c.Compression,
+		"cacheableMethods":     stringList(methods),
+		"cacheableStatuses":    statuses,
+		"negativeStatuses":     negativeStatuses,
+		"cacheInfoPath":        c.CacheInfoPath,
+		"purgePath":            c.PurgePath,
+		"metricsPath":          c.MetricsPath,
+	}
+}
+
+func stringList(values []string) []interface{} {
+	list := make([]interface{}, len(values))
+	for index, value := range values {
+		list[index] = value
+	}
+	return list
+}
+
+// backendStats picks raw statistics of backend by names, values are converted into numbers if possible
+func backendStats(raw map[string]string, names map[string]string) map[string]interface{} {
+	stats := map[string]interface{}{}
+	for rawName, name := range names {
+		value, ok := raw[rawName]
+		if !ok {
+			continue
+		}
+		if number, e := strconv.ParseInt(value, 10, 64); e == nil {
+			stats[name] = number
+		} else {
+			stats[name] = value
+		}
+	}
+	return stats
+}
+
+// formatInfoText formats information as sorted "name: value" lines, nested names are joined by dot
+func formatInfoText(info map[string]interface{}) string {
+	lines := []string{}
+	flattenInfo("", info, &lines)
+	sort.Strings(lines)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func flattenInfo(name string, value interface{}, lines *[]string) {
+	join := func(key string) string {
+		if name == "" {
+			return key
+		}
+		return name + "." + key
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			flattenInfo(join(key), item, lines)
+		}
+	case []interface{}:
+		for index, item := range v {
+			flattenInfo(join(strconv.Itoa(index)), item, lines)
+		}
+	case *healthCheckResult:
+		flattenInfo(name, map[string]interface{}{
+			"setResult":    v.SetResult,
+			"getResult":    v.GetResult,
+			"deleteResult": v.DeleteResult,
+		}, lines)
+	default:
+		*lines = append(*lines, fmt.Sprintf("%s: %v", name, v))
+	}
+}
@@ -0,0 +1,130 @@
+package cacheman
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	echo4 "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteInfoV4ShouldReportCountersBackendAndConfig(t *testing.T) {
+	conf := &Config{
+		Enabled:       true,
+		TTL:           "1m",
+		Namespace:     "shop",
+		Password:      "secret",
+		CacheInfoPath: "/cache/info",
+		Rules:         []Rule{{Path: "/products/:id", TTL: "10m"}},
+		ExcludedPaths: []string{"/products/private"},
+	}
+	cache, e := NewBigCache(conf)
+	assert.NoError(t, e)
+	server := newEchoV4Server(conf, cache, func(ctx echo4.Context) error {
+		return ctx.String(http.StatusOK, "product")
+	})
+	for index := 0; index < 2; index++ {
+		serveRequest(server, http.MethodGet, "/products/1")
+	}
+
+	rec := serveRequest(server, http.MethodGet, "/cache/info")
+
+	var info map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &info))
+	assert.Equal(t, "*cacheman.BigCacheClient", info["type"])
+	assert.Equal(t, HealthCheckPassed, info["operationHealth"].(map[string]interface{})["getResult"])
+	assert.Contains(t, info, "uptime")
+	counters := info["counters"].(map[string]interface{})
+	assert.Equal(t, float64(1), counters[MetricHits])
+	assert.Equal(t, float64(1), counters[MetricMisses])
+	assert.Equal(t, float64(1), counters[MetricStores])
+	latency := info["backendLatency"].(map[string]interface{})[OperationGet].(map[string]interface{})
+	assert.NotZero(t, latency["count"])
+	backend := info["backend"].(map[string]interface{})
+	assert.Equal(t, float64(1), backend["entries"])
+	config := info["config"].(map[string]interface{})
+	assert.Equal(t, "shop", config["namespace"])
+	assert.Equal(t, []interface{}{"/products/private"}, config["excludedRoutes"])
+	assert.Equal(t, "10m0s", config["rules"].([]interface{})[0].(map[string]interface{})["ttl"])
+	assert.NotContains(t, rec.Body.String(), "secret")
+}
+
+func TestWriteInfoV4ShouldWriteTextFormat(t *testing.T) {
+	conf := &Config{
+		Enabled:       true,
+		TTL:           "1m",
+		CacheInfoPath: "/cache/info",
+		Rules:         []Rule{{Path: "/products/:id", Tags: []string{"products"}}},
+	}
+	cache, e := NewBigCache(conf)
+	assert.NoError(t, e)
+	server := newEchoV4Server(conf, cache, func(ctx echo4.Context) error {
+		return ctx.String(http.StatusOK, "product")
+	})
+
+	rec := serveRequest(server, http.MethodGet, "/cache/info?format=text")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "type: *cacheman.BigCacheClient\n")
+	assert.Contains(t, rec.Body.String(), "operationHealth.deleteResult: passed\n")
+	assert.Contains(t, rec.Body.String(), "operationHealth.setResult: passed\n")
+	assert.Contains(t, rec.Body.String(), "config.rules.0.path: /products/:id\n")
+	assert.Contains(t, rec.Body.String(), "config.rules.0.tags.0: products\n")
+	assert.Contains(t, rec.Body.String(), "counters.hits: 0\n")
+}
+
+func TestParseRedisInfoShouldSkipSectionsAndBlankLines(t *testing.T) {
+	info := "# Memory\r\nused_memory:1024\r\nused_memory_human:1.00K\r\n\r\n# Stats\r\nkeyspace_hits:7\r\n"
+
+	stats := backendStats(parseRedisInfo(info), map[string]string{
+		"used_memory":       "memoryBytes",
+		"used_memory_human": "memoryHuman",
+		"keyspace_hits":     "hits",
+		"keyspace_misses":   "misses",
+	})
+
+	assert.Equal(t, map[string]interface{}{
+		"memoryBytes": int64(1024),
+		"memoryHuman": "1.00K",
+		"hits":        int64(7),
+	}, stats)
+}
+
+func TestMemcachedStatsShouldReadStatsReply(t *testing.T) {
+	listener, e := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, e)
+	defer listener.Close()
+	go func() {
+		conn, e := listener.Accept()
+		if e != nil {
+			return
+		}
+		defer conn.Close()
+		command, _ := bufio.NewReader(conn).ReadString('\n')
+		if command == "stats\r\n" {
+			fmt.Fprint(conn, "STAT curr_items 3\r\nSTAT bytes 512\r\nSTAT version 1.6.9\r\nEND\r\n")
+		}
+	}()
+
+	cache, _ := NewMemcached(&Config{Server: listener.Addr().String()})
+	stats, e := cache.Stats()
+
+	assert.NoError(t, e)
+	assert.Equal(t, int64(3), stats["entries"])
+	assert.Equal(t, int64(512), stats["memoryBytes"])
+}
+
+func TestManagerObserveShouldKeepMaxLatency(t *testing.T) {
+	cm := NewCacheManager(&Config{}, nil)
+
+	cm.observe(OperationGet, "", 2*time.Millisecond)
+	cm.observe(OperationGet, "", time.Millisecond)
+
+	assert.Equal(t, uint64(2), cm.stats.latencies[OperationGet].count)
+	assert.Equal(t, uint64(2*time.Millisecond), cm.stats.latencies[OperationGet].max)
+}
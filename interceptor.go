@@ -1,6 +1,9 @@
 package cacheman
 
 import (
+	"bufio"
+	"errors"
+	"net"
 	"net/http"
 )
 
@@ -8,10 +11,15 @@ import (
 type Interceptor struct {
 	writer    http.ResponseWriter
 	committed bool
+	buffered  bool
 
-	status  int
-	header  http.Header
-	content []byte
+	status      int
+	header      http.Header
+	content     []byte
+	tags        []string
+	maxBodySize int
+	overflowed  bool
+	hijacked    bool
 }
 
 // NewInterceptor creates a new response interceptor
@@ -21,6 +29,21 @@ func NewInterceptor(writer http.ResponseWriter) *Interceptor {
 	}
 }
 
+// NewBufferedInterceptor creates a response interceptor which holds status and content
+// until Commit is called, so the response can still be discarded
+func NewBufferedInterceptor(writer http.ResponseWriter) *Interceptor {
+	return &Interceptor{
+		writer:   writer,
+		buffered: true,
+	}
+}
+
+// SetMaxBodySize limits size of captured content. Content is no longer captured once it grows beyond the limit,
+// while it is still written out. Zero means no limit.
+func (c *Interceptor) SetMaxBodySize(size int) {
+	c.maxBodySize = size
+}
+
 // Header returns response header
 func (c *Interceptor) Header() http.Header {
 	c.header = c.writer.Header()
@@ -28,12 +51,29 @@ func (c *Interceptor) Header() http.Header {
 }
 
 // Write writes out the content. Automatically writes out the header if it has not been written out.
+// Every chunk is captured, so content of handler writing in several chunks is complete.
 func (c *Interceptor) Write(b []byte) (int, error) {
 	if !c.committed {
 		c.WriteHeader(http.StatusOK)
 	}
-	c.content = b
-	return c.writer.Write(c.content)
+	if !c.overflowed && c.maxBodySize > 0 && len(c.content)+len(b) > c.maxBodySize {
+		c.overflowed = true
+		if c.buffered {
+			if e := c.Commit(); e != nil {
+				return 0, e
+			}
+		}
+		c.content = nil
+	}
+	if c.buffered {
+		c.content = append(c.content, b...)
+		return len(b), nil
+	}
+	n, e := c.writer.Write(b)
+	if !c.overflowed {
+		c.content = append(c.content, b[:n]...)
+	}
+	return n, e
 }
 
 // WriteHeader writes out the header with given status code
@@ -42,10 +82,60 @@ func (c *Interceptor) WriteHeader(statusCode int) {
 		return
 	}
 	c.status = statusCode
-	c.writer.WriteHeader(c.status)
+	c.tags = extractTags(c.writer.Header())
+	if !c.buffered {
+		c.writer.WriteHeader(c.status)
+	}
 	c.committed = true
 }
 
+// Commit writes out status and content held by buffered interceptor, then turns it into pass-through
+func (c *Interceptor) Commit() error {
+	if !c.buffered {
+		return nil
+	}
+	c.buffered = false
+	if c.status == 0 {
+		return nil
+	}
+	c.writer.WriteHeader(c.status)
+	if len(c.content) == 0 {
+		return nil
+	}
+	_, e := c.writer.Write(c.content)
+	return e
+}
+
+// Flush sends content written so far to client. Buffered interceptor is committed first.
+func (c *Interceptor) Flush() {
+	if !c.committed {
+		c.WriteHeader(http.StatusOK)
+	}
+	c.Commit()
+	if flusher, ok := c.writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack lets handler take over the connection, e.g. for websocket. Hijacked response is never cached.
+func (c *Interceptor) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := c.writer.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	c.hijacked = true
+	return hijacker.Hijack()
+}
+
+// Push initiates HTTP/2 server push if it is supported by response writer
+func (c *Interceptor) Push(target string, opts *http.PushOptions) error {
+	pusher, ok := c.writer.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+	return pusher.Push(target, opts)
+}
+
 // Status returns the captured status
 func (c *Interceptor) Status() int {
 	return c.status
@@ -55,3 +145,14 @@ func (c *Interceptor) Status() int {
 func (c *Interceptor) Content() []byte {
 	return c.content
 }
+
+// Tags returns tags taken from Surrogate-Key or Cache-Tag header, which are not sent to client
+func (c *Interceptor) Tags() []string {
+	return c.tags
+}
+
+// Complete returns true if the whole response has been captured,
+// i.e. its content is within size limit and connection is not hijacked
+func (c *Interceptor) Complete() bool {
+	return c.status != 0 && !c.overflowed && !c.hijacked
+}
@@ -2,6 +2,7 @@ package cacheman
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -52,6 +53,7 @@ func TestWriteHeaderShouldCacheStatusCode(t *testing.T) {
 	mockWriter := new(MockResponseWriter)
 	expectedCode := 204
 
+	mockWriter.On("Header").Return(http.Header{})
 	mockWriter.On("WriteHeader", mock.AnythingOfType("int"))
 
 	interceptor := NewInterceptor(mockWriter)
@@ -67,6 +69,7 @@ func TestWriteHeaderShouldNotReinvokeWriteHeader(t *testing.T) {
 	mockWriter := new(MockResponseWriter)
 	expectedCode := 204
 
+	mockWriter.On("Header").Return(http.Header{})
 	mockWriter.On("WriteHeader", mock.AnythingOfType("int"))
 
 	interceptor := NewInterceptor(mockWriter)
@@ -84,6 +87,7 @@ func TestWriteWithoutWritingHeaderShouldCacheContentWithHttp200OK(t *testing.T)
 	expectedCode := 200
 	expectedContent := []byte{1, 2, 3, 4}
 
+	mockWriter.On("Header").Return(http.Header{})
 	mockWriter.On("WriteHeader", mock.AnythingOfType("int"))
 	mockWriter.On("Write", mock.AnythingOfType("[]uint8")).Return(4, nil)
 
@@ -103,6 +107,7 @@ func TestWriteWithWritingHeaderEarlierShouldCacheContentWithHttp200OK(t *testing
 	expectedCode := 201
 	expectedContent := []byte{1, 2, 3, 4}
 
+	mockWriter.On("Header").Return(http.Header{})
 	mockWriter.On("WriteHeader", mock.AnythingOfType("int"))
 	mockWriter.On("Write", mock.AnythingOfType("[]uint8")).Return(4, nil)
 
@@ -117,3 +122,103 @@ func TestWriteWithWritingHeaderEarlierShouldCacheContentWithHttp200OK(t *testing
 	mockWriter.AssertCalled(t, "Write", expectedContent)
 	assert.Equal(t, expectedContent, interceptor.Content())
 }
+
+func TestWriteHeaderShouldStripTagHeaders(t *testing.T) {
+	mockWriter := new(MockResponseWriter)
+	header := http.Header{}
+	header.Set("Surrogate-Key", "product-42 category-7")
+	header.Set("Cache-Tag", "sale")
+	header.Set("Content-Type", "text/plain")
+
+	mockWriter.On("Header").Return(header)
+	mockWriter.On("WriteHeader", mock.AnythingOfType("int"))
+
+	interceptor := NewInterceptor(mockWriter)
+
+	interceptor.WriteHeader(200)
+
+	assert.Equal(t, []string{"product-42", "category-7", "sale"}, interceptor.Tags())
+	assert.Empty(t, header.Get("Surrogate-Key"))
+	assert.Empty(t, header.Get("Cache-Tag"))
+	assert.Equal(t, "text/plain", header.Get("Content-Type"))
+}
+
+func TestWriteInChunksShouldCaptureWholeContent(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	interceptor := NewInterceptor(recorder)
+
+	interceptor.Write([]byte("hello "))
+	interceptor.Write([]byte("world"))
+
+	assert.Equal(t, []byte("hello world"), interceptor.Content())
+	assert.Equal(t, "hello world", recorder.Body.String())
+	assert.True(t, interceptor.Complete())
+}
+
+func TestWriteBeyondMaxBodySizeShouldStopCapturingButPassThrough(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	interceptor := NewInterceptor(recorder)
+	interceptor.SetMaxBodySize(8)
+
+	interceptor.Write([]byte("hello "))
+	interceptor.Write([]byte("world"))
+
+	assert.Empty(t, interceptor.Content())
+	assert.Equal(t, "hello world", recorder.Body.String())
+	assert.False(t, interceptor.Complete())
+}
+
+func TestBufferedInterceptorShouldHoldResponseUntilCommit(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	interceptor := NewBufferedInterceptor(recorder)
+
+	interceptor.WriteHeader(201)
+	interceptor.Write([]byte("hello "))
+	interceptor.Write([]byte("world"))
+
+	assert.Equal(t, 0, recorder.Body.Len())
+	assert.False(t, recorder.Flushed)
+
+	interceptor.Commit()
+
+	assert.Equal(t, 201, recorder.Code)
+	assert.Equal(t, "hello world", recorder.Body.String())
+}
+
+func TestBufferedInterceptorBeyondMaxBodySizeShouldCommit(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	interceptor := NewBufferedInterceptor(recorder)
+	interceptor.SetMaxBodySize(8)
+
+	interceptor.Write([]byte("hello "))
+	interceptor.Write([]byte("world"))
+
+	assert.Equal(t, "hello world", recorder.Body.String())
+	assert.False(t, interceptor.Complete())
+}
+
+func TestFlushShouldPassThrough(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	interceptor := NewBufferedInterceptor(recorder)
+
+	interceptor.Write([]byte("chunk"))
+	interceptor.Flush()
+
+	assert.True(t, recorder.Flushed)
+	assert.Equal(t, "chunk", recorder.Body.String())
+	assert.Equal(t, []byte("chunk"), interceptor.Content())
+}
+
+func TestHijackShouldFailIfWriterDoesNotSupportIt(t *testing.T) {
+	interceptor := NewInterceptor(httptest.NewRecorder())
+
+	_, _, e := interceptor.Hijack()
+
+	assert.Error(t, e)
+}
+
+func TestPushShouldFailIfWriterDoesNotSupportIt(t *testing.T) {
+	interceptor := NewInterceptor(httptest.NewRecorder())
+
+	assert.Equal(t, http.ErrNotSupported, interceptor.Push("/style.css", nil))
+}
@@ -1,5 +1,17 @@
 package cacheman
 
+import (
+	"errors"
+	"time"
+
+	"github.com/allegro/bigcache"
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrCacheMiss can be returned by Get of custom cache for missing key, so it is not logged as an error
+var ErrCacheMiss = errors.New("cache miss")
+
 // CacheInterface defines interface for cache
 type CacheInterface interface {
 	Get(key string) ([]byte, error)
@@ -8,3 +20,36 @@ type CacheInterface interface {
 	Reset() error
 	Type() string
 }
+
+// TTLSetter is implemented by cache supporting TTL per entry
+type TTLSetter interface {
+	SetWithTTL(key string, value []byte, ttl time.Duration) error
+}
+
+// PrefixDeleter is implemented by cache which can delete entries by key prefix
+type PrefixDeleter interface {
+	DeletePrefix(prefix string) error
+}
+
+// PatternDeleter is implemented by cache which can delete entries by glob pattern of key.
+// * matches any sequence of characters, ? matches any single character.
+type PatternDeleter interface {
+	DeletePattern(pattern string) error
+}
+
+// TagIndexer is implemented by cache which can index entries by tag and delete them by tag
+type TagIndexer interface {
+	AddTags(key string, tags []string, ttl time.Duration) error
+	DeleteTags(tags ...string) error
+}
+
+// StatsReporter is implemented by cache which can report its statistics, e.g. entries, memory use, hits and misses
+type StatsReporter interface {
+	Stats() (map[string]interface{}, error)
+}
+
+// isCacheMiss returns true if error of Get means key is not in cache
+func isCacheMiss(e error) bool {
+	return errors.Is(e, ErrCacheMiss) || errors.Is(e, bigcache.ErrEntryNotFound) ||
+		errors.Is(e, redis.Nil) || errors.Is(e, memcache.ErrCacheMiss)
+}
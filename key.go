@@ -0,0 +1,168 @@
+package cacheman
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	echo4 "github.com/labstack/echo/v4"
+)
+
+// KeyFunc builds cache key from request
+type KeyFunc func(req *http.Request) string
+
+// EchoKeyFunc builds cache key from echo V4 context, e.g. from JWT claims set by earlier middleware
+type EchoKeyFunc func(ctx echo4.Context) string
+
+// keyBuilderContext is request context key of cache key builder given by framework adapter
+type keyBuilderContext struct{}
+
+// RequestURIKey uses request URI as cache key. It is default key builder.
+func RequestURIKey(req *http.Request) string {
+	if req.RequestURI != "" {
+		return req.RequestURI
+	}
+	return req.URL.RequestURI()
+}
+
+// SortedQueryKey uses path and query parameters sorted by name as cache key,
+// so /search?b=2&a=1 and /search?a=1&b=2 share the same cache entry
+func SortedQueryKey(req *http.Request) string {
+	return withQuery(req.URL.EscapedPath(), req.URL.Query())
+}
+
+// IgnoreParamsKey builds cache key like SortedQueryKey without given query parameters.
+// Parameter ending with * ignores every parameter with that prefix, e.g. utm_*.
+func IgnoreParamsKey(params ...string) KeyFunc {
+	return func(req *http.Request) string {
+		query := req.URL.Query()
+		for name := range query {
+			if ignoredParam(name, params) {
+				query.Del(name)
+			}
+		}
+		return withQuery(req.URL.EscapedPath(), query)
+	}
+}
+
+// HeaderKey appends values of given request headers to cache key built by base
+func HeaderKey(base KeyFunc, headers ...string) KeyFunc {
+	return func(req *http.Request) string {
+		key := base(req)
+		for _, header := range headers {
+			key = fmt.Sprintf("%s|%s=%s", key, http.CanonicalHeaderKey(header), strings.Join(req.Header.Values(header), ","))
+		}
+		return key
+	}
+}
+
+// HashedKey replaces cache key built by base with its path and SHA-256 hash if it is longer than maxLength
+func HashedKey(base KeyFunc, maxLength int) KeyFunc {
+	return func(req *http.Request) string {
+		key := base(req)
+		if len(key) <= maxLength {
+			return key
+		}
+		return fmt.Sprintf("%s#%x", req.URL.EscapedPath(), sha256.Sum256([]byte(key)))
+	}
+}
+
+// withQuery appends encoded query, sorted by parameter name, to path
+func withQuery(path string, query url.Values) string {
+	if len(query) == 0 {
+		return path
+	}
+	return path + "?" + query.Encode()
+}
+
+// ignoredParam returns true if name matches one of params
+func ignoredParam(name string, params []string) bool {
+	for _, param := range params {
+		if strings.HasSuffix(param, "*") {
+			if strings.HasPrefix(name, strings.TrimSuffix(param, "*")) {
+				return true
+			}
+		} else if name == param {
+			return true
+		}
+	}
+	return false
+}
+
+// withEchoKey gives request EchoKeyFunc of echo V4 context to build its cache key
+func (c *Manager) withEchoKey(req *http.Request, ctx echo4.Context) *http.Request {
+	if c.EchoKeyFunc == nil {
+		return req
+	}
+	return withKeyBuilder(req, func() string {
+		return c.EchoKeyFunc(ctx)
+	})
+}
+
+// withKeyBuilder gives request its own cache key builder in place of KeyFunc
+func withKeyBuilder(req *http.Request, build func() string) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), keyBuilderContext{}, build))
+}
+
+// keyBuilder returns cache key builder given to request, if any
+func keyBuilder(req *http.Request) (func() string, bool) {
+	build, ok := req.Context().Value(keyBuilderContext{}).(func() string)
+	return build, ok
+}
+
+// keyable returns false if EchoKeyFunc is set but request does not come with echo V4 context,
+// then it must not be cached by KeyFunc which does not tell apart what EchoKeyFunc does
+func (c *Manager) keyable(req *http.Request) bool {
+	if c.EchoKeyFunc == nil {
+		return true
+	}
+	_, ok := keyBuilder(req)
+	return ok
+}
+
+// baseKey builds cache key of request by builder given by framework adapter, or by KeyFunc
+func (c *Manager) baseKey(req *http.Request) string {
+	if build, ok := keyBuilder(req); ok {
+		return build()
+	}
+	return c.KeyFunc(req)
+}
+
+// requestKey returns cache key of request. HEAD shares cache key with GET,
+// cache key of POST includes hash of its body.
+func (c *Manager) requestKey(req *http.Request) string {
+	key := c.baseKey(req)
+	if req.Method == http.MethodPost {
+		body, _ := readBody(req)
+		key = fmt.Sprintf("%s#post=%x", key, sha256.Sum256(body))
+	}
+	return key
+}
+
+// readBody reads request body and leaves it readable again, for handler and later reads
+func readBody(req *http.Request) ([]byte, error) {
+	if req.GetBody != nil {
+		body, e := req.GetBody()
+		if e != nil {
+			return nil, e
+		}
+		defer body.Close()
+		return ioutil.ReadAll(body)
+	}
+	if req.Body == nil {
+		return []byte{}, nil
+	}
+	b, e := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}
+	return b, e
+}
@@ -0,0 +1,147 @@
+package cacheman
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	echo4 "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestURIKey(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/search?b=2&a=1", nil)
+
+	assert.Equal(t, "/search?b=2&a=1", RequestURIKey(req))
+}
+
+func TestSortedQueryKeyShouldSortParameters(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/search?b=2&a=1&a=0", nil)
+
+	assert.Equal(t, "/search?a=1&a=0&b=2", SortedQueryKey(req))
+}
+
+func TestSortedQueryKeyWithoutQuery(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/search", nil)
+
+	assert.Equal(t, "/search", SortedQueryKey(req))
+}
+
+func TestIgnoreParamsKeyShouldDropParameters(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/search?q=shoe&utm_source=mail&utm_medium=email&fbclid=1", nil)
+
+	key := IgnoreParamsKey("utm_*", "fbclid")(req)
+
+	assert.Equal(t, "/search?q=shoe", key)
+}
+
+func TestHeaderKeyShouldAppendHeaderValues(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("X-Tenant-Id", "acme")
+
+	key := HeaderKey(RequestURIKey, "x-tenant-id")(req)
+
+	assert.Equal(t, "/me|X-Tenant-Id=acme", key)
+}
+
+func TestHashedKeyShouldHashLongKey(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/search?q="+strings.Repeat("x", 100), nil)
+
+	key := HashedKey(RequestURIKey, 32)(req)
+
+	assert.True(t, strings.HasPrefix(key, "/search#"))
+	assert.Len(t, key, len("/search#")+64)
+}
+
+func TestHashedKeyShouldKeepShortKey(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/search?q=x", nil)
+
+	assert.Equal(t, "/search?q=x", HashedKey(RequestURIKey, 32)(req))
+}
+
+func TestMiddlewareV4ShouldUseKeyFuncForLookupAndStore(t *testing.T) {
+	calls := 0
+	conf := &Config{
+		Enabled: true,
+		TTL:     "1m",
+		Paths:   []string{"/search"},
+		KeyFunc: IgnoreParamsKey("utm_*"),
+	}
+	server := echo4.New()
+	server.Use(MiddlewareV4(conf, newMemoryCache()))
+	server.GET("/search", func(ctx echo4.Context) error {
+		calls++
+		return ctx.String(http.StatusOK, ctx.QueryParam("q"))
+	})
+
+	for _, uri := range []string{"/search?q=shoe&utm_source=a", "/search?utm_source=b&q=shoe", "/search?q=shoe"} {
+		req := httptest.NewRequest(http.MethodGet, uri, nil)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		assert.Equal(t, "shoe", rec.Body.String())
+	}
+	assert.Equal(t, 1, calls)
+}
+
+func TestMiddlewareV4ShouldUseEchoKeyFunc(t *testing.T) {
+	var calls int32
+	cm := NewCacheManager(&Config{
+		Enabled: true,
+		Rules:   []Rule{{Path: "/products/:id", TTL: "10ms", StaleWhileRevalidate: "1m"}},
+		EchoKeyFunc: func(ctx echo4.Context) string {
+			return fmt.Sprintf("%s|tenant=%s", ctx.Request().URL.Path, ctx.Get("tenant"))
+		},
+	}, newMemoryCache())
+	server := echo4.New()
+	server.Use(func(next echo4.HandlerFunc) echo4.HandlerFunc {
+		return func(ctx echo4.Context) error {
+			ctx.Set("tenant", ctx.QueryParam("tenant"))
+			return next(ctx)
+		}
+	})
+	server.Use(MiddlewareV4WithManager(cm))
+	server.GET("/products/:id", func(ctx echo4.Context) error {
+		return ctx.String(http.StatusOK, fmt.Sprintf("%s-%d", ctx.QueryParam("tenant"), atomic.AddInt32(&calls, 1)))
+	})
+
+	assert.Equal(t, "acme-1", serveRequest(server, http.MethodGet, "/products/1?tenant=acme").Body.String())
+	assert.Equal(t, "globex-2", serveRequest(server, http.MethodGet, "/products/1?tenant=globex").Body.String())
+	assert.Equal(t, "acme-1", serveRequest(server, http.MethodGet, "/products/1?tenant=acme&utm_source=mail").Body.String())
+
+	ctx := server.NewContext(httptest.NewRequest(http.MethodGet, "/products/1", nil), httptest.NewRecorder())
+	ctx.Set("tenant", "globex")
+	assert.True(t, cm.TryWriteV4(ctx))
+
+	// Stale content is refreshed into entry of the same tenant
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, "acme-1", serveRequest(server, http.MethodGet, "/products/1?tenant=acme").Body.String())
+	assert.Eventually(t, func() bool {
+		return serveRequest(server, http.MethodGet, "/products/1?tenant=acme").Body.String() == "acme-3"
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestHTTPMiddlewareShouldNotCacheIfEchoKeyFuncIsSet(t *testing.T) {
+	var calls int32
+	conf := &Config{
+		Enabled:   true,
+		TTL:       "1m",
+		Paths:     []string{"/products/:id"},
+		PurgePath: "/purge",
+		EchoKeyFunc: func(ctx echo4.Context) string {
+			return ctx.Request().URL.Path
+		},
+	}
+	cm := NewCacheManager(conf, newMemoryCache())
+	server := newHTTPServer(conf, &calls)
+
+	for index := 0; index < 2; index++ {
+		assert.Empty(t, serveRequest(server, http.MethodGet, "/products/1").Header().Get("X-Cache"))
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	assert.False(t, cm.TryWrite(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/products/1", nil)))
+	assert.Equal(t, http.StatusNotImplemented, serveRequest(server, "PURGE", "/products/1").Code)
+}
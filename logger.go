@@ -0,0 +1,176 @@
+package cacheman
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	echo4 "github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+// Logger logs messages with key/value fields, e.g. Debug("Cache hits", "key", key, "route", route)
+type Logger interface {
+	Debug(msg string, fields ...interface{})
+	Info(msg string, fields ...interface{})
+	Warn(msg string, fields ...interface{})
+	Error(msg string, fields ...interface{})
+}
+
+// LogLevel is the lowest level of messages logged by TextLogger
+type LogLevel int
+
+const (
+	// LogDebug logs every message
+	LogDebug LogLevel = iota
+	// LogInfo logs info, warn and error messages
+	LogInfo
+	// LogWarn logs warn and error messages
+	LogWarn
+	// LogError logs error messages only
+	LogError
+)
+
+var logLevelNames = map[LogLevel]string{
+	LogDebug: "debug",
+	LogInfo:  "info",
+	LogWarn:  "warn",
+	LogError: "error",
+}
+
+// NopLogger discards every message
+type NopLogger struct{}
+
+// Debug discards debug message
+func (NopLogger) Debug(msg string, fields ...interface{}) {}
+
+// Info discards info message
+func (NopLogger) Info(msg string, fields ...interface{}) {}
+
+// Warn discards warn message
+func (NopLogger) Warn(msg string, fields ...interface{}) {}
+
+// Error discards error message
+func (NopLogger) Error(msg string, fields ...interface{}) {}
+
+// TextLogger writes messages in logfmt-like lines, e.g. level=debug msg="Cache hits" key=/products/1
+type TextLogger struct {
+	writer io.Writer
+	level  LogLevel
+	lock   sync.Mutex
+}
+
+// NewTextLogger creates a logger writing messages of level and above to writer
+func NewTextLogger(writer io.Writer, level LogLevel) *TextLogger {
+	return &TextLogger{
+		writer: writer,
+		level:  level,
+	}
+}
+
+// Debug logs debug message
+func (l *TextLogger) Debug(msg string, fields ...interface{}) {
+	l.log(LogDebug, msg, fields)
+}
+
+// Info logs info message
+func (l *TextLogger) Info(msg string, fields ...interface{}) {
+	l.log(LogInfo, msg, fields)
+}
+
+// Warn logs warn message
+func (l *TextLogger) Warn(msg string, fields ...interface{}) {
+	l.log(LogWarn, msg, fields)
+}
+
+// Error logs error message
+func (l *TextLogger) Error(msg string, fields ...interface{}) {
+	l.log(LogError, msg, fields)
+}
+
+func (l *TextLogger) log(level LogLevel, msg string, fields []interface{}) {
+	if level < l.level {
+		return
+	}
+	var line strings.Builder
+	fmt.Fprintf(&line, "level=%s msg=%s", logLevelNames[level], quoteLogValue(msg))
+	for index := 0; index < len(fields); index += 2 {
+		key, value := logField(fields, index)
+		fmt.Fprintf(&line, " %s=%s", key, quoteLogValue(formatLogValue(value)))
+	}
+	line.WriteString("\n")
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	io.WriteString(l.writer, line.String())
+}
+
+// EchoLogger logs messages to echo logger as JSON
+type EchoLogger struct {
+	logger echo4.Logger
+}
+
+// NewEchoLogger creates a logger backed by echo logger, e.g. of echo.Echo.Logger
+func NewEchoLogger(logger echo4.Logger) *EchoLogger {
+	return &EchoLogger{
+		logger: logger,
+	}
+}
+
+// Debug logs debug message
+func (l *EchoLogger) Debug(msg string, fields ...interface{}) {
+	l.logger.Debugj(logJSON(msg, fields))
+}
+
+// Info logs info message
+func (l *EchoLogger) Info(msg string, fields ...interface{}) {
+	l.logger.Infoj(logJSON(msg, fields))
+}
+
+// Warn logs warn message
+func (l *EchoLogger) Warn(msg string, fields ...interface{}) {
+	l.logger.Warnj(logJSON(msg, fields))
+}
+
+// Error logs error message
+func (l *EchoLogger) Error(msg string, fields ...interface{}) {
+	l.logger.Errorj(logJSON(msg, fields))
+}
+
+func logJSON(msg string, fields []interface{}) log.JSON {
+	output := log.JSON{"message": msg}
+	for index := 0; index < len(fields); index += 2 {
+		key, value := logField(fields, index)
+		output[key] = formatLogValue(value)
+	}
+	return output
+}
+
+// logField returns key and value of field at index. Key without value gets an empty value.
+func logField(fields []interface{}, index int) (string, interface{}) {
+	key := fmt.Sprint(fields[index])
+	if index+1 >= len(fields) {
+		return key, ""
+	}
+	return key, fields[index+1]
+}
+
+func formatLogValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case time.Duration:
+		return v.String()
+	case error:
+		return v.Error()
+	}
+	return fmt.Sprint(value)
+}
+
+func quoteLogValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		return fmt.Sprintf("%q", value)
+	}
+	return value
+}
@@ -0,0 +1,44 @@
+//go:build go1.21
+// +build go1.21
+
+package cacheman
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogLogger logs messages to slog logger
+type SlogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogger creates a logger backed by slog logger, or slog.Default() if logger is nil
+func NewSlogLogger(logger *slog.Logger) *SlogLogger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &SlogLogger{
+		logger: logger,
+	}
+}
+
+// Debug logs debug message
+func (l *SlogLogger) Debug(msg string, fields ...interface{}) {
+	l.logger.Log(context.Background(), slog.LevelDebug, msg, fields...)
+}
+
+// Info logs info message
+func (l *SlogLogger) Info(msg string, fields ...interface{}) {
+	l.logger.Log(context.Background(), slog.LevelInfo, msg, fields...)
+}
+
+// Warn logs warn message
+func (l *SlogLogger) Warn(msg string, fields ...interface{}) {
+	l.logger.Log(context.Background(), slog.LevelWarn, msg, fields...)
+}
+
+// Error logs error message
+func (l *SlogLogger) Error(msg string, fields ...interface{}) {
+	l.logger.Log(context.Background(), slog.LevelError, msg, fields...)
+}
@@ -0,0 +1,21 @@
+//go:build go1.21
+// +build go1.21
+
+package cacheman
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlogLoggerShouldWriteFieldsToSlog(t *testing.T) {
+	var output bytes.Buffer
+	logger := NewSlogLogger(slog.New(slog.NewTextHandler(&output, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	logger.Debug("Cache gets", "key", "/products/1", "result", "hit")
+
+	assert.Contains(t, output.String(), `level=DEBUG msg="Cache gets" key=/products/1 result=hit`)
+}
@@ -0,0 +1,119 @@
+package cacheman
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	echo4 "github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type recordingLogger struct {
+	lock    sync.Mutex
+	entries []string
+}
+
+func (l *recordingLogger) record(level, msg string, fields []interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.entries = append(l.entries, strings.TrimSuffix(fmt.Sprintln(append([]interface{}{level, msg}, fields...)...), "\n"))
+}
+
+func (l *recordingLogger) Debug(msg string, fields ...interface{}) { l.record("debug", msg, fields) }
+func (l *recordingLogger) Info(msg string, fields ...interface{})  { l.record("info", msg, fields) }
+func (l *recordingLogger) Warn(msg string, fields ...interface{})  { l.record("warn", msg, fields) }
+func (l *recordingLogger) Error(msg string, fields ...interface{}) { l.record("error", msg, fields) }
+
+func (l *recordingLogger) levels() []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	levels := []string{}
+	for _, entry := range l.entries {
+		levels = append(levels, strings.Fields(entry)[0])
+	}
+	return levels
+}
+
+func TestTextLoggerShouldWriteFieldsOfEnabledLevels(t *testing.T) {
+	var output bytes.Buffer
+	logger := NewTextLogger(&output, LogInfo)
+
+	logger.Debug("Cache gets", "key", "/products/1")
+	logger.Info("Cache purges key", "key", "/products/1", "latency", 1500*time.Microsecond)
+	logger.Error("Cache fails to set", "error", errors.New("connection refused"), "dangling")
+
+	assert.Equal(t, "level=info msg=\"Cache purges key\" key=/products/1 latency=1.5ms\n"+
+		"level=error msg=\"Cache fails to set\" error=\"connection refused\" dangling=\"\"\n", output.String())
+}
+
+func TestEchoLoggerShouldWriteJSONToEchoLogger(t *testing.T) {
+	var output bytes.Buffer
+	server := echo4.New()
+	server.Logger.SetOutput(&output)
+	server.Logger.SetLevel(log.DEBUG)
+	logger := NewEchoLogger(server.Logger)
+
+	logger.Warn("Cache fails to decode", "key", "/products/1", "status", 200)
+
+	assert.Contains(t, output.String(), `"level":"WARN"`)
+	assert.Contains(t, output.String(), `"message":"Cache fails to decode"`)
+	assert.Contains(t, output.String(), `"key":"/products/1"`)
+	assert.Contains(t, output.String(), `"status":"200"`)
+}
+
+func TestNewCacheManagerShouldLogToStdoutOnlyIfVerbose(t *testing.T) {
+	assert.IsType(t, &TextLogger{}, NewCacheManager(&Config{Verbose: true}, nil).Logger)
+	assert.IsType(t, NopLogger{}, NewCacheManager(&Config{}, nil).Logger)
+
+	logger := &recordingLogger{}
+	cm := NewCacheManager(&Config{Logger: logger}, nil)
+	cm.Log("Something happens")
+	assert.Equal(t, []string{"debug Something happens"}, logger.entries)
+}
+
+func TestManagerShouldLogAdapterErrors(t *testing.T) {
+	logger := &recordingLogger{}
+	cache := new(MockCache)
+	cache.On("Get", "/products/1#vary").Return([]byte{}, ErrCacheMiss)
+	cache.On("Get", "/products/1").Return([]byte{}, errors.New("connection refused"))
+	cache.On("Set", "/products/1", mock.Anything).Return(errors.New("connection refused"))
+	cache.On("Delete", "/products/1#vary").Return(nil)
+	server := echo4.New()
+	server.Use(MiddlewareV4(&Config{
+		Enabled: true,
+		TTL:     "1m",
+		Paths:   []string{"/products/:id"},
+		Logger:  logger,
+	}, cache))
+	server.GET("/products/:id", func(ctx echo4.Context) error {
+		return ctx.String(http.StatusOK, "product")
+	})
+
+	server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/products/1", nil))
+
+	errorEntries := []string{}
+	for index, level := range logger.levels() {
+		if level == "error" {
+			errorEntries = append(errorEntries, logger.entries[index])
+		}
+	}
+	assert.Len(t, errorEntries, 2)
+	assert.Contains(t, errorEntries[0], "Cache fails to get")
+	assert.Contains(t, errorEntries[0], "route /products/:id")
+	assert.Contains(t, errorEntries[1], "Cache fails to set")
+	assert.Contains(t, errorEntries[1], "connection refused")
+}
+
+func TestIsCacheMissShouldRecognizeMissOfAdapters(t *testing.T) {
+	assert.True(t, isCacheMiss(ErrCacheMiss))
+	assert.False(t, isCacheMiss(errors.New("connection refused")))
+}
@@ -0,0 +1,212 @@
+package cacheman
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache events counted by MetricsCollector
+const (
+	// MetricHits counts requests served from cache
+	MetricHits = "hits"
+	// MetricMisses counts requests looked up in cache but served by handler
+	MetricMisses = "misses"
+	// MetricStores counts responses stored into cache
+	MetricStores = "stores"
+	// MetricStoreFailures counts responses failed to be stored into cache
+	MetricStoreFailures = "store_failures"
+	// MetricPurges counts purges
+	MetricPurges = "purges"
+	// MetricBypasses counts requests of cached routes not looked up in cache, e.g. by policy
+	MetricBypasses = "bypasses"
+	// MetricDecodeErrors counts cached content failed to be decoded
+	MetricDecodeErrors = "decode_errors"
+	// MetricNegativeHits counts requests served from cached failure statuses, they are also counted as hits
+	MetricNegativeHits = "negative_hits"
+	// MetricNegativeStores counts failure statuses stored into cache, they are also counted as stores
+	MetricNegativeStores = "negative_stores"
+)
+
+// Cache operations observed by MetricsCollector
+const (
+	// OperationGet is Get of cache backend
+	OperationGet = "get"
+	// OperationSet is Set of cache backend
+	OperationSet = "set"
+)
+
+// MetricsCollector collects metrics of cache manager. Route is path of matched rule,
+// or empty if operation is not bound to a route.
+type MetricsCollector interface {
+	// Count counts an event of route
+	Count(event string, route string)
+	// ObserveBackend observes latency of a cache backend operation of route
+	ObserveBackend(operation string, route string, duration time.Duration)
+}
+
+// nopMetrics discards metrics
+type nopMetrics struct{}
+
+func (nopMetrics) Count(event string, route string) {}
+
+func (nopMetrics) ObserveBackend(operation string, route string, duration time.Duration) {}
+
+var metricEvents = []struct {
+	event string
+	help  string
+}{
+	{MetricHits, "Requests served from cache."},
+	{MetricMisses, "Requests looked up in cache but served by handler."},
+	{MetricStores, "Responses stored into cache."},
+	{MetricStoreFailures, "Responses failed to be stored into cache."},
+	{MetricPurges, "Cache purges."},
+	{MetricBypasses, "Requests of cached routes not looked up in cache."},
+	{MetricDecodeErrors, "Cached content failed to be decoded."},
+	{MetricNegativeHits, "Requests served from cached failure statuses."},
+	{MetricNegativeStores, "Failure statuses stored into cache."},
+}
+
+// DefaultLatencyBuckets are upper bounds in seconds of backend latency histogram buckets
+var DefaultLatencyBuckets = []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+type metricKey struct {
+	name  string
+	route string
+}
+
+type histogram struct {
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+// PrometheusMetrics collects metrics in memory and exposes them in Prometheus text format as http.Handler
+type PrometheusMetrics struct {
+	buckets    []float64
+	lock       sync.Mutex
+	counters   map[metricKey]uint64
+	histograms map[metricKey]*histogram
+}
+
+// NewPrometheusMetrics creates a Prometheus metrics collector with DefaultLatencyBuckets
+func NewPrometheusMetrics() *PrometheusMetrics {
+	return NewPrometheusMetricsWithBuckets(DefaultLatencyBuckets)
+}
+
+// NewPrometheusMetricsWithBuckets creates a Prometheus metrics collector with latency buckets in seconds
+func NewPrometheusMetricsWithBuckets(buckets []float64) *PrometheusMetrics {
+	sorted := append([]float64{}, buckets...)
+	sort.Float64s(sorted)
+	return &PrometheusMetrics{
+		buckets:    sorted,
+		counters:   map[metricKey]uint64{},
+		histograms: map[metricKey]*histogram{},
+	}
+}
+
+// Count counts an event of route
+func (m *PrometheusMetrics) Count(event string, route string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.counters[metricKey{event, route}]++
+}
+
+// ObserveBackend observes latency of a cache backend operation of route
+func (m *PrometheusMetrics) ObserveBackend(operation string, route string, duration time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	key := metricKey{operation, route}
+	h, ok := m.histograms[key]
+	if !ok {
+		h = &histogram{buckets: make([]uint64, len(m.buckets))}
+		m.histograms[key] = h
+	}
+	seconds := duration.Seconds()
+	for index, bound := range m.buckets {
+		if seconds <= bound {
+			h.buckets[index]++
+			break
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// Counter returns count of an event of route
+func (m *PrometheusMetrics) Counter(event string, route string) uint64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.counters[metricKey{event, route}]
+}
+
+// ServeHTTP writes metrics in Prometheus text format
+func (m *PrometheusMetrics) ServeHTTP(writer http.ResponseWriter, req *http.Request) {
+	writer.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	writer.WriteHeader(http.StatusOK)
+	m.Expose(writer)
+}
+
+// Expose writes metrics in Prometheus text format
+func (m *PrometheusMetrics) Expose(writer io.Writer) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for _, metric := range metricEvents {
+		name := fmt.Sprintf("cacheman_%s_total", metric.event)
+		fmt.Fprintf(writer, "# HELP %s %s\n# TYPE %s counter\n", name, metric.help, name)
+		for _, key := range counterKeys(m.counters, metric.event) {
+			fmt.Fprintf(writer, "%s{route=\"%s\"} %d\n", name, escapeLabel(key.route), m.counters[key])
+		}
+	}
+
+	name := "cacheman_backend_duration_seconds"
+	fmt.Fprintf(writer, "# HELP %s Latency of cache backend operations.\n# TYPE %s histogram\n", name, name)
+	keys := []metricKey{}
+	for key := range m.histograms {
+		keys = append(keys, key)
+	}
+	sortMetricKeys(keys)
+	for _, key := range keys {
+		h := m.histograms[key]
+		labels := fmt.Sprintf("operation=\"%s\",route=\"%s\"", escapeLabel(key.name), escapeLabel(key.route))
+		cumulative := uint64(0)
+		for index, bound := range m.buckets {
+			cumulative += h.buckets[index]
+			fmt.Fprintf(writer, "%s_bucket{%s,le=\"%g\"} %d\n", name, labels, bound, cumulative)
+		}
+		fmt.Fprintf(writer, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+		fmt.Fprintf(writer, "%s_sum{%s} %g\n", name, labels, h.sum)
+		fmt.Fprintf(writer, "%s_count{%s} %d\n", name, labels, h.count)
+	}
+}
+
+func counterKeys(counters map[metricKey]uint64, name string) []metricKey {
+	keys := []metricKey{}
+	for key := range counters {
+		if key.name == name {
+			keys = append(keys, key)
+		}
+	}
+	sortMetricKeys(keys)
+	return keys
+}
+
+func sortMetricKeys(keys []metricKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].name != keys[j].name {
+			return keys[i].name < keys[j].name
+		}
+		return keys[i].route < keys[j].route
+	})
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
@@ -0,0 +1,143 @@
+package cacheman
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	echo4 "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func productIDHandler(ctx echo4.Context) error {
+	return ctx.String(http.StatusOK, "product "+ctx.Param("id"))
+}
+
+func TestPrometheusMetricsShouldExposeCountersAndHistograms(t *testing.T) {
+	metrics := NewPrometheusMetricsWithBuckets([]float64{0.1, 0.01})
+	metrics.Count(MetricHits, "/products/:id")
+	metrics.Count(MetricHits, "/products/:id")
+	metrics.Count(MetricMisses, `/say/"hi"`)
+	metrics.ObserveBackend(OperationGet, "/products/:id", 5*time.Millisecond)
+	metrics.ObserveBackend(OperationGet, "/products/:id", 50*time.Millisecond)
+
+	var output strings.Builder
+	metrics.Expose(&output)
+
+	assert.Contains(t, output.String(), "# TYPE cacheman_hits_total counter\n")
+	assert.Contains(t, output.String(), "cacheman_hits_total{route=\"/products/:id\"} 2\n")
+	assert.Contains(t, output.String(), "cacheman_misses_total{route=\"/say/\\\"hi\\\"\"} 1\n")
+	assert.Contains(t, output.String(), "# TYPE cacheman_backend_duration_seconds histogram\n")
+	assert.Contains(t, output.String(), "cacheman_backend_duration_seconds_bucket{operation=\"get\",route=\"/products/:id\",le=\"0.01\"} 1\n")
+	assert.Contains(t, output.String(), "cacheman_backend_duration_seconds_bucket{operation=\"get\",route=\"/products/:id\",le=\"0.1\"} 2\n")
+	assert.Contains(t, output.String(), "cacheman_backend_duration_seconds_bucket{operation=\"get\",route=\"/products/:id\",le=\"+Inf\"} 2\n")
+	assert.Contains(t, output.String(), "cacheman_backend_duration_seconds_count{operation=\"get\",route=\"/products/:id\"} 2\n")
+}
+
+func TestMiddlewareV4ShouldCountHitsMissesAndStores(t *testing.T) {
+	metrics := NewPrometheusMetrics()
+	server := newEchoV4Server(&Config{
+		Enabled: true,
+		TTL:     "1m",
+		Rules:   []Rule{{Path: "/products/:id"}},
+		Metrics: metrics,
+	}, newMemoryCache(), productIDHandler)
+
+	for index := 0; index < 3; index++ {
+		serveRequest(server, http.MethodGet, "/products/1")
+	}
+
+	assert.Equal(t, uint64(1), metrics.Counter(MetricMisses, "/products/:id"))
+	assert.Equal(t, uint64(1), metrics.Counter(MetricStores, "/products/:id"))
+	assert.Equal(t, uint64(2), metrics.Counter(MetricHits, "/products/:id"))
+}
+
+func TestMiddlewareV4ShouldCountNegativeHitsAndStores(t *testing.T) {
+	metrics := NewPrometheusMetrics()
+	server := newEchoV4Server(&Config{
+		Enabled:          true,
+		TTL:              "1m",
+		Rules:            []Rule{{Path: "/products/:id"}},
+		NegativeStatuses: map[int]string{http.StatusNotFound: ""},
+		Metrics:          metrics,
+	}, newMemoryCache(), func(ctx echo4.Context) error {
+		if ctx.Param("id") == "404" {
+			return echo4.NewHTTPError(http.StatusNotFound, "no such product")
+		}
+		return productIDHandler(ctx)
+	})
+
+	for _, uri := range []string{"/products/404", "/products/404", "/products/1", "/products/1"} {
+		serveRequest(server, http.MethodGet, uri)
+	}
+
+	assert.Equal(t, uint64(2), metrics.Counter(MetricStores, "/products/:id"))
+	assert.Equal(t, uint64(1), metrics.Counter(MetricNegativeStores, "/products/:id"))
+	assert.Equal(t, uint64(2), metrics.Counter(MetricHits, "/products/:id"))
+	assert.Equal(t, uint64(1), metrics.Counter(MetricNegativeHits, "/products/:id"))
+}
+
+func TestMiddlewareV4ShouldCountBypassesAndPurges(t *testing.T) {
+	metrics := NewPrometheusMetrics()
+	server := newEchoV4Server(&Config{
+		Enabled:   true,
+		TTL:       "1m",
+		Rules:     []Rule{{Path: "/products/:id"}},
+		Policy:    PolicyRespectHeaders,
+		Metrics:   metrics,
+		PurgePath: "/purge",
+	}, newMemoryCache(), productIDHandler)
+
+	req := httptest.NewRequest(http.MethodGet, "/products/1", nil)
+	req.Header.Set("Cache-Control", "no-cache")
+	server.ServeHTTP(httptest.NewRecorder(), req)
+	serveRequest(server, "PURGE", "/products/1")
+
+	assert.Equal(t, uint64(1), metrics.Counter(MetricBypasses, "/products/:id"))
+	assert.Equal(t, uint64(1), metrics.Counter(MetricPurges, ""))
+}
+
+func TestMiddlewareV4ShouldCountDecodeErrorsAndStoreFailures(t *testing.T) {
+	metrics := NewPrometheusMetrics()
+	cache := new(MockCache)
+	cache.On("Get", "/products/1#vary").Return([]byte{}, errors.New("miss"))
+	cache.On("Get", "/products/1").Return([]byte{binaryMagic, 0}, nil)
+	cache.On("Set", "/products/1", mock.Anything).Return(errors.New("full"))
+	cache.On("Delete", "/products/1#vary").Return(nil)
+	server := newEchoV4Server(&Config{
+		Enabled: true,
+		TTL:     "1m",
+		Rules:   []Rule{{Path: "/products/:id"}},
+		Metrics: metrics,
+	}, cache, productIDHandler)
+
+	rec := serveRequest(server, http.MethodGet, "/products/1")
+
+	assert.Equal(t, "product 1", rec.Body.String())
+	assert.Equal(t, uint64(1), metrics.Counter(MetricDecodeErrors, "/products/:id"))
+	assert.Equal(t, uint64(1), metrics.Counter(MetricMisses, "/products/:id"))
+	assert.Equal(t, uint64(1), metrics.Counter(MetricStoreFailures, "/products/:id"))
+}
+
+func TestMiddlewareV4ShouldServeMetrics(t *testing.T) {
+	metrics := NewPrometheusMetrics()
+	server := newEchoV4Server(&Config{
+		Enabled:     true,
+		TTL:         "1m",
+		Rules:       []Rule{{Path: "/products/:id"}},
+		Metrics:     metrics,
+		MetricsPath: "/metrics",
+	}, newMemoryCache(), productIDHandler)
+	serveRequest(server, http.MethodGet, "/products/1")
+
+	rec := serveRequest(server, http.MethodGet, "/metrics")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain"))
+	assert.Contains(t, rec.Body.String(), "cacheman_stores_total{route=\"/products/:id\"} 1\n")
+	assert.Contains(t, rec.Body.String(), "cacheman_backend_duration_seconds_count{operation=\"set\",route=\"/products/:id\"} 1\n")
+}
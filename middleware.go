@@ -1,68 +1,102 @@
 package cacheman
 
 import (
-	"encoding/base64"
-	"encoding/json"
-	"fmt"
 	"net/http"
 
+	echo3 "github.com/labstack/echo"
 	echo4 "github.com/labstack/echo/v4"
 )
 
+// Middleware creates a middleware to handle cache for echo V3
+func Middleware(config *Config, cache CacheInterface) echo3.MiddlewareFunc {
+	return MiddlewareWithManager(NewCacheManager(config, cache))
+}
+
+// MiddlewareWithManager creates a middleware to handle cache for echo V3 with an existing cache manager
+func MiddlewareWithManager(cm *Manager) echo3.MiddlewareFunc {
+	return func(next echo3.HandlerFunc) echo3.HandlerFunc {
+		return func(ctx echo3.Context) error {
+			// AdminAuth.Authorize takes echo V4 context, so admin requests are denied if it is set
+			handled, e := cm.serve(ctx.Response().Writer, ctx.Request(), cm.nextV3(ctx, next), cm.backgroundV3(ctx, next), nil)
+			if !handled {
+				return next(ctx)
+			}
+			return e
+		}
+	}
+}
+
 // MiddlewareV4 creates a middleware to handle cache for echo V4
 func MiddlewareV4(config *Config, cache CacheInterface) echo4.MiddlewareFunc {
-	cm = NewCacheManager(config, cache)
+	return MiddlewareV4WithManager(NewCacheManager(config, cache))
+}
+
+// MiddlewareV4WithManager creates a middleware to handle cache for echo V4 with an existing cache manager
+func MiddlewareV4WithManager(cm *Manager) echo4.MiddlewareFunc {
 	return func(next echo4.HandlerFunc) echo4.HandlerFunc {
 		return func(ctx echo4.Context) error {
-			if cm.Enabled {
-				cm.Log(fmt.Sprintf("Test path: %s", ctx.Request().RequestURI))
-				if ctx.Request().Method == "GET" {
-					if enabledByPath(config.CacheInfoPath, ctx.Request().URL.Path) {
-						cm.Log("Cache info request")
-						cm.WriteInfoV4(ctx)
-					} else {
-						if cm.TestPath(ctx.Request().URL.Path) {
-							cm.Log(fmt.Sprintf("Path matches: %s", ctx.Request().RequestURI))
+			req := cm.withEchoKey(ctx.Request(), ctx)
+			handled, e := cm.serve(ctx.Response().Writer, req, cm.nextV4(ctx, next), cm.backgroundV4(ctx, next), cm.authorizeV4(ctx))
+			if !handled {
+				return next(ctx)
+			}
+			return e
+		}
+	}
+}
 
-							interceptor := NewInterceptor(ctx.Response().Writer)
-							ctx.Response().Writer = interceptor
+// nextV3 runs echo handler writing into given writer
+func (c *Manager) nextV3(ctx echo3.Context, next echo3.HandlerFunc) handlerFunc {
+	return func(writer http.ResponseWriter, req *http.Request) error {
+		ctx.Response().Writer = writer
+		return c.renderNegativeV3(ctx, next(ctx))
+	}
+}
 
-							if !cm.TryWriteV4(ctx) {
-								e := next(ctx)
-								// Store into cache only if status is 200
-								if e == nil && interceptor.Status() == 200 {
-									content := Content{
-										Status:  interceptor.Status(),
-										Headers: interceptor.Header(),
-										Content: base64.StdEncoding.EncodeToString(interceptor.Content()),
-									}
-									stringifiedCache, e := json.Marshal(content)
-									if e == nil {
-										cacheKey := ctx.Request().RequestURI
-										cm.Set(cacheKey, stringifiedCache)
-									}
-								}
-								return e
-							}
-							return nil
-						} else {
-							cm.Log(fmt.Sprintf("Path does not match: %s", ctx.Request().RequestURI))
-						}
-					}
-				} else {
-					if ctx.Request().Method == "PURGE" && enabledByPath(config.PurgePath, ctx.Request().URL.Path) {
-						cm.Purge()
-						ctx.NoContent(http.StatusOK)
-						return nil
-					}
-					cm.Log(fmt.Sprintf("Method does not match: %s", ctx.Request().Method))
-				}
-			}
-			return next(ctx)
+// backgroundV3 runs echo handler in background on its own context
+func (c *Manager) backgroundV3(ctx echo3.Context, next echo3.HandlerFunc) backgroundFunc {
+	return func(writer http.ResponseWriter, req *http.Request) func() error {
+		refreshCtx := &refreshContextV3{
+			Context: ctx.Echo().NewContext(req, writer),
+			path:    ctx.Path(),
+			names:   append([]string{}, ctx.ParamNames()...),
+			values:  append([]string{}, ctx.ParamValues()...),
+		}
+		return func() error {
+			return c.renderNegativeV3(refreshCtx, next(refreshCtx))
 		}
 	}
 }
 
-func enabledByPath(expectedPath, actualPath string) bool {
-	return expectedPath != "" && expectedPath == actualPath
+// nextV4 runs echo handler writing into given writer
+func (c *Manager) nextV4(ctx echo4.Context, next echo4.HandlerFunc) handlerFunc {
+	return func(writer http.ResponseWriter, req *http.Request) error {
+		ctx.Response().Writer = writer
+		return c.renderNegativeV4(ctx, next(ctx))
+	}
+}
+
+// backgroundV4 runs echo handler in background on its own context
+func (c *Manager) backgroundV4(ctx echo4.Context, next echo4.HandlerFunc) backgroundFunc {
+	return func(writer http.ResponseWriter, req *http.Request) func() error {
+		refreshCtx := &refreshContext{
+			Context: ctx.Echo().NewContext(req, writer),
+			path:    ctx.Path(),
+			names:   append([]string{}, ctx.ParamNames()...),
+			values:  append([]string{}, ctx.ParamValues()...),
+		}
+		return func() error {
+			return c.renderNegativeV4(refreshCtx, next(refreshCtx))
+		}
+	}
+}
+
+// authorizeV4 returns custom echo check of admin request, or nil if there is none
+func (c *Manager) authorizeV4(ctx echo4.Context) func() bool {
+	if c.AdminAuth == nil || c.AdminAuth.Authorize == nil {
+		return nil
+	}
+	return func() bool {
+		return c.AdminAuth.Authorize(ctx)
+	}
 }
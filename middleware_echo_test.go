@@ -0,0 +1,242 @@
+package cacheman
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	echo3 "github.com/labstack/echo"
+	echo4 "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// echoVersion runs the same middleware tests against every supported echo version.
+// Handler of /products/:id responds its body, or returns echo HTTP error if status is an error.
+type echoVersion struct {
+	name string
+	// evaluatesAuthorize is true if version can evaluate AdminAuth.Authorize
+	evaluatesAuthorize bool
+	newServer          func(cm *Manager, handler func(id string) (int, string)) http.Handler
+	tryWrite           func(cm *Manager, writer http.ResponseWriter, req *http.Request) bool
+	writeInfo          func(cm *Manager, writer http.ResponseWriter, req *http.Request)
+}
+
+var echoVersions = []echoVersion{
+	{
+		name: "V3",
+		newServer: func(cm *Manager, handler func(id string) (int, string)) http.Handler {
+			server := echo3.New()
+			server.Use(MiddlewareWithManager(cm))
+			server.GET("/products/:id", func(ctx echo3.Context) error {
+				status, body := handler(ctx.Param("id"))
+				if negative(status) {
+					return echo3.NewHTTPError(status, body)
+				}
+				return ctx.String(status, body)
+			})
+			return server
+		},
+		tryWrite: func(cm *Manager, writer http.ResponseWriter, req *http.Request) bool {
+			return cm.TryWriteV3(echo3.New().NewContext(req, writer))
+		},
+		writeInfo: func(cm *Manager, writer http.ResponseWriter, req *http.Request) {
+			cm.WriteInfoV3(echo3.New().NewContext(req, writer))
+		},
+	},
+	{
+		name:               "V4",
+		evaluatesAuthorize: true,
+		newServer: func(cm *Manager, handler func(id string) (int, string)) http.Handler {
+			server := echo4.New()
+			server.Use(MiddlewareV4WithManager(cm))
+			server.GET("/products/:id", func(ctx echo4.Context) error {
+				status, body := handler(ctx.Param("id"))
+				if negative(status) {
+					return echo4.NewHTTPError(status, body)
+				}
+				return ctx.String(status, body)
+			})
+			return server
+		},
+		tryWrite: func(cm *Manager, writer http.ResponseWriter, req *http.Request) bool {
+			return cm.TryWriteV4(echo4.New().NewContext(req, writer))
+		},
+		writeInfo: func(cm *Manager, writer http.ResponseWriter, req *http.Request) {
+			cm.WriteInfoV4(echo4.New().NewContext(req, writer))
+		},
+	},
+}
+
+// newEchoV4Server creates echo V4 server with cache middleware of conf and cache, and handler at /products/:id
+func newEchoV4Server(conf *Config, cache CacheInterface, handler echo4.HandlerFunc) *echo4.Echo {
+	server := echo4.New()
+	server.Use(MiddlewareV4(conf, cache))
+	server.GET("/products/:id", handler)
+	return server
+}
+
+func TestEchoMiddlewareShouldServeCachedContent(t *testing.T) {
+	for _, version := range echoVersions {
+		t.Run(version.name, func(t *testing.T) {
+			var calls int32
+			cm := NewCacheManager(&Config{
+				Enabled:           true,
+				TTL:               "1m",
+				Paths:             []string{"/products/:id"},
+				AdditionalHeaders: map[string]string{"X-Served-By": "cacheman"},
+			}, newMemoryCache())
+			server := version.newServer(cm, func(id string) (int, string) {
+				return http.StatusOK, fmt.Sprintf("%s-%d", id, atomic.AddInt32(&calls, 1))
+			})
+
+			rec := serveRequest(server, http.MethodGet, "/products/1")
+			assert.Equal(t, "1-1", rec.Body.String())
+			assert.Equal(t, CacheMiss, rec.Header().Get("X-Cache"))
+
+			rec = serveRequest(server, http.MethodGet, "/products/1")
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "1-1", rec.Body.String())
+			assert.Equal(t, CacheHit, rec.Header().Get("X-Cache"))
+			assert.Equal(t, "cacheman", rec.Header().Get("X-Served-By"))
+		})
+	}
+}
+
+func TestEchoMiddlewareShouldCacheReturnedNotFoundError(t *testing.T) {
+	for _, version := range echoVersions {
+		t.Run(version.name, func(t *testing.T) {
+			var calls int32
+			cm := NewCacheManager(&Config{
+				Enabled:          true,
+				TTL:              "1m",
+				Paths:            []string{"/products/:id"},
+				NegativeStatuses: map[int]string{http.StatusNotFound: ""},
+			}, newMemoryCache())
+			server := version.newServer(cm, func(id string) (int, string) {
+				atomic.AddInt32(&calls, 1)
+				return http.StatusNotFound, "no such product"
+			})
+
+			for index := 0; index < 2; index++ {
+				rec := serveRequest(server, http.MethodGet, "/products/404")
+				assert.Equal(t, http.StatusNotFound, rec.Code)
+				assert.Contains(t, rec.Body.String(), "no such product")
+			}
+			assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+		})
+	}
+}
+
+func TestEchoMiddlewareShouldServeStaleWhileRevalidate(t *testing.T) {
+	for _, version := range echoVersions {
+		t.Run(version.name, func(t *testing.T) {
+			var calls int32
+			cm := NewCacheManager(&Config{
+				Enabled: true,
+				Rules: []Rule{
+					{Path: "/products/:id", TTL: "10ms", StaleWhileRevalidate: "1m"},
+				},
+			}, newMemoryCache())
+			server := version.newServer(cm, func(id string) (int, string) {
+				return http.StatusOK, fmt.Sprintf("%s-%d", id, atomic.AddInt32(&calls, 1))
+			})
+
+			assert.Equal(t, "7-1", serveRequest(server, http.MethodGet, "/products/7").Body.String())
+			time.Sleep(20 * time.Millisecond)
+			assert.Equal(t, "7-1", serveRequest(server, http.MethodGet, "/products/7").Body.String())
+			assert.Eventually(t, func() bool {
+				return serveRequest(server, http.MethodGet, "/products/7").Body.String() == "7-2"
+			}, time.Second, 5*time.Millisecond)
+		})
+	}
+}
+
+func TestEchoMiddlewareShouldPurge(t *testing.T) {
+	for _, version := range echoVersions {
+		t.Run(version.name, func(t *testing.T) {
+			var calls int32
+			cm := NewCacheManager(&Config{
+				Enabled:   true,
+				TTL:       "1m",
+				Paths:     []string{"/products/:id"},
+				PurgePath: "/purge",
+			}, newMemoryCache())
+			server := version.newServer(cm, func(id string) (int, string) {
+				return http.StatusOK, fmt.Sprintf("%s-%d", id, atomic.AddInt32(&calls, 1))
+			})
+			serveRequest(server, http.MethodGet, "/products/1")
+
+			assert.Equal(t, http.StatusOK, serveRequest(server, "PURGE", "/products/1").Code)
+			assert.Equal(t, "1-2", serveRequest(server, http.MethodGet, "/products/1").Body.String())
+		})
+	}
+}
+
+func TestEchoMiddlewareShouldFailClosedOnAuthorize(t *testing.T) {
+	for _, version := range echoVersions {
+		t.Run(version.name, func(t *testing.T) {
+			cm := NewCacheManager(&Config{
+				Enabled:   true,
+				TTL:       "1m",
+				Paths:     []string{"/products/:id"},
+				PurgePath: "/purge",
+				AdminAuth: &AdminAuth{
+					Authorize: func(ctx echo4.Context) bool {
+						return true
+					},
+				},
+			}, newMemoryCache())
+			server := version.newServer(cm, func(id string) (int, string) {
+				return http.StatusOK, "product"
+			})
+
+			expected := http.StatusForbidden
+			if version.evaluatesAuthorize {
+				expected = http.StatusOK
+			}
+			assert.Equal(t, expected, serveRequest(server, "PURGE", "/purge").Code)
+		})
+	}
+}
+
+func TestEchoTryWriteShouldWriteFreshContent(t *testing.T) {
+	for _, version := range echoVersions {
+		t.Run(version.name, func(t *testing.T) {
+			cm := NewCacheManager(&Config{Enabled: true, TTL: "1m", Paths: []string{"/products/:id"}}, newMemoryCache())
+			server := version.newServer(cm, func(id string) (int, string) {
+				return http.StatusOK, "product"
+			})
+
+			rec := httptest.NewRecorder()
+			assert.False(t, version.tryWrite(cm, rec, httptest.NewRequest(http.MethodGet, "/products/1", nil)))
+			serveRequest(server, http.MethodGet, "/products/1")
+
+			rec = httptest.NewRecorder()
+			assert.True(t, version.tryWrite(cm, rec, httptest.NewRequest(http.MethodGet, "/products/1", nil)))
+			assert.Equal(t, "product", rec.Body.String())
+			assert.Equal(t, CacheHit, rec.Header().Get("X-Cache"))
+		})
+	}
+}
+
+func TestEchoWriteInfoShouldWriteJSONOrText(t *testing.T) {
+	for _, version := range echoVersions {
+		t.Run(version.name, func(t *testing.T) {
+			cm := NewCacheManager(&Config{Enabled: true, TTL: "1m", Namespace: "shop"}, newMemoryCache())
+
+			rec := httptest.NewRecorder()
+			version.writeInfo(cm, rec, httptest.NewRequest(http.MethodGet, "/cache/info", nil))
+			var info map[string]interface{}
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &info))
+			assert.Equal(t, "shop", info["config"].(map[string]interface{})["namespace"])
+
+			rec = httptest.NewRecorder()
+			version.writeInfo(cm, rec, httptest.NewRequest(http.MethodGet, "/cache/info?format=text", nil))
+			assert.Contains(t, rec.Body.String(), "config.namespace: shop\n")
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package cacheman
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MiddlewareGin creates a middleware to handle cache for gin
+func MiddlewareGin(config *Config, cache CacheInterface) gin.HandlerFunc {
+	return MiddlewareGinWithManager(NewCacheManager(config, cache))
+}
+
+// MiddlewareGinWithManager creates a middleware to handle cache for gin with an existing cache manager
+func MiddlewareGinWithManager(cm *Manager) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// Handler chain of gin context cannot be replayed once request completes, so stale content is not refreshed in background
+		handled, _ := cm.serve(ctx.Writer, ctx.Request, nextGin(ctx), nil, nil)
+		if !handled {
+			ctx.Next()
+			return
+		}
+		ctx.Abort()
+	}
+}
+
+// nextGin runs rest of gin handler chain writing into given writer
+func nextGin(ctx *gin.Context) handlerFunc {
+	return func(writer http.ResponseWriter, req *http.Request) error {
+		original := ctx.Writer
+		ginWriter := newGinResponseWriter(writer)
+		ctx.Writer = ginWriter
+		ctx.Next()
+		ginWriter.WriteHeaderNow()
+		ctx.Writer = original
+		return nil
+	}
+}
+
+// ginResponseWriter is gin response writer on top of http.ResponseWriter, e.g. Interceptor.
+// Like gin's own writer, status is sent with first write of body.
+type ginResponseWriter struct {
+	writer http.ResponseWriter
+	status int
+	size   int
+}
+
+func newGinResponseWriter(writer http.ResponseWriter) *ginResponseWriter {
+	return &ginResponseWriter{
+		writer: writer,
+		status: http.StatusOK,
+		size:   -1,
+	}
+}
+
+func (w *ginResponseWriter) Header() http.Header {
+	return w.writer.Header()
+}
+
+func (w *ginResponseWriter) WriteHeader(statusCode int) {
+	if statusCode > 0 && !w.Written() {
+		w.status = statusCode
+	}
+}
+
+func (w *ginResponseWriter) WriteHeaderNow() {
+	if !w.Written() {
+		w.size = 0
+		w.writer.WriteHeader(w.status)
+	}
+}
+
+func (w *ginResponseWriter) Write(b []byte) (int, error) {
+	w.WriteHeaderNow()
+	n, e := w.writer.Write(b)
+	w.size += n
+	return n, e
+}
+
+func (w *ginResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *ginResponseWriter) Status() int {
+	return w.status
+}
+
+func (w *ginResponseWriter) Size() int {
+	return w.size
+}
+
+func (w *ginResponseWriter) Written() bool {
+	return w.size != -1
+}
+
+func (w *ginResponseWriter) Flush() {
+	w.WriteHeaderNow()
+	if flusher, ok := w.writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *ginResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.writer.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	if w.size < 0 {
+		w.size = 0
+	}
+	return hijacker.Hijack()
+}
+
+func (w *ginResponseWriter) CloseNotify() <-chan bool {
+	if notifier, ok := w.writer.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+	return make(chan bool)
+}
+
+func (w *ginResponseWriter) Pusher() http.Pusher {
+	if pusher, ok := w.writer.(http.Pusher); ok {
+		return pusher
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package cacheman
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	echo4 "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func newGinServer(conf *Config, calls *int32) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	server.Use(MiddlewareGin(conf, newMemoryCache()))
+	server.GET("/products/:id", func(ctx *gin.Context) {
+		call := atomic.AddInt32(calls, 1)
+		ctx.String(http.StatusOK, "%s-%d", ctx.Param("id"), call)
+	})
+	server.GET("/missing/:id", func(ctx *gin.Context) {
+		atomic.AddInt32(calls, 1)
+		ctx.Status(http.StatusNotFound)
+	})
+	return server
+}
+
+func TestMiddlewareGinShouldServeCachedContentWithAdditionalHeaders(t *testing.T) {
+	var calls int32
+	server := newGinServer(&Config{
+		Enabled:           true,
+		TTL:               "1m",
+		Paths:             []string{"/products/:id"},
+		AdditionalHeaders: map[string]string{"X-Served-By": "cacheman"},
+	}, &calls)
+
+	for index := 0; index < 2; index++ {
+		rec := serveRequest(server, http.MethodGet, "/products/1")
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "1-1", rec.Body.String())
+		assert.Equal(t, "text/plain; charset=utf-8", rec.Header().Get("Content-Type"))
+	}
+	rec := serveRequest(server, http.MethodGet, "/products/1")
+
+	assert.Equal(t, CacheHit, rec.Header().Get("X-Cache"))
+	assert.Equal(t, "cacheman", rec.Header().Get("X-Served-By"))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestMiddlewareGinShouldSkipExcludedPaths(t *testing.T) {
+	var calls int32
+	server := newGinServer(&Config{
+		Enabled:       true,
+		TTL:           "1m",
+		Paths:         []string{"/products/:id"},
+		ExcludedPaths: []string{"/products/2"},
+	}, &calls)
+
+	for index := 0; index < 2; index++ {
+		rec := serveRequest(server, http.MethodGet, "/products/2")
+
+		assert.Empty(t, rec.Header().Get("X-Cache"))
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestMiddlewareGinShouldKeepStatusWithoutBody(t *testing.T) {
+	var calls int32
+	server := newGinServer(&Config{
+		Enabled:           true,
+		TTL:               "1m",
+		Paths:             []string{"/missing/:id"},
+		CacheableStatuses: map[int]string{http.StatusNotFound: "1m"},
+	}, &calls)
+
+	for index := 0; index < 2; index++ {
+		rec := serveRequest(server, http.MethodGet, "/missing/1")
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestMiddlewareGinShouldPurgeAndWriteInfo(t *testing.T) {
+	var calls int32
+	server := newGinServer(&Config{
+		Enabled:       true,
+		TTL:           "1m",
+		Paths:         []string{"/products/:id"},
+		PurgePath:     "/purge",
+		CacheInfoPath: "/cache/info",
+	}, &calls)
+	serveRequest(server, http.MethodGet, "/products/1")
+
+	assert.Equal(t, http.StatusOK, serveRequest(server, "PURGE", "/purge").Code)
+	assert.Equal(t, "1-2", serveRequest(server, http.MethodGet, "/products/1").Body.String())
+
+	rec := serveRequest(server, http.MethodGet, "/cache/info?format=text")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), fmt.Sprintf("counters.%s: 1\n", MetricPurges))
+}
+
+func TestMiddlewareGinShouldRefreshExpiredContentInRequest(t *testing.T) {
+	var calls int32
+	server := newGinServer(&Config{
+		Enabled: true,
+		Rules: []Rule{
+			{Path: "/products/:id", TTL: "10ms", StaleWhileRevalidate: "1m"},
+		},
+	}, &calls)
+
+	assert.Equal(t, "7-1", serveRequest(server, http.MethodGet, "/products/7").Body.String())
+	time.Sleep(20 * time.Millisecond)
+	rec := serveRequest(server, http.MethodGet, "/products/7")
+	assert.Equal(t, "7-2", rec.Body.String())
+	assert.Equal(t, CacheMiss, rec.Header().Get("X-Cache"))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestMiddlewareGinShouldDenyAdminRequestIfEchoAuthorizeIsSet(t *testing.T) {
+	var calls int32
+	server := newGinServer(&Config{
+		Enabled:   true,
+		TTL:       "1m",
+		Paths:     []string{"/products/:id"},
+		PurgePath: "/purge",
+		AdminAuth: &AdminAuth{
+			Authorize: func(ctx echo4.Context) bool {
+				return true
+			},
+		},
+	}, &calls)
+
+	assert.Equal(t, http.StatusForbidden, serveRequest(server, "PURGE", "/purge").Code)
+}
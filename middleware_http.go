@@ -0,0 +1,40 @@
+package cacheman
+
+import (
+	"net/http"
+)
+
+// HTTPMiddleware creates a middleware to handle cache for net/http
+func HTTPMiddleware(config *Config, cache CacheInterface) func(http.Handler) http.Handler {
+	return HTTPMiddlewareWithManager(NewCacheManager(config, cache))
+}
+
+// HTTPMiddlewareWithManager creates a middleware to handle cache for net/http with an existing cache manager
+func HTTPMiddlewareWithManager(cm *Manager) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+			handled, _ := cm.serve(writer, req, nextHTTP(next), backgroundHTTP(next), nil)
+			if !handled {
+				next.ServeHTTP(writer, req)
+			}
+		})
+	}
+}
+
+// nextHTTP runs handler writing into given writer, net/http handler has no error to return
+func nextHTTP(next http.Handler) handlerFunc {
+	return func(writer http.ResponseWriter, req *http.Request) error {
+		next.ServeHTTP(writer, req)
+		return nil
+	}
+}
+
+// backgroundHTTP runs handler in background
+func backgroundHTTP(next http.Handler) backgroundFunc {
+	return func(writer http.ResponseWriter, req *http.Request) func() error {
+		return func() error {
+			next.ServeHTTP(writer, req)
+			return nil
+		}
+	}
+}
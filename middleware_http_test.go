@@ -0,0 +1,145 @@
+package cacheman
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	echo4 "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func newHTTPServer(conf *Config, calls *int32) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/products/", func(writer http.ResponseWriter, req *http.Request) {
+		call := atomic.AddInt32(calls, 1)
+		writer.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(writer, "%s-%d", req.URL.Path, call)
+	})
+	return HTTPMiddleware(conf, newMemoryCache())(mux)
+}
+
+func TestHTTPMiddlewareShouldServeCachedContent(t *testing.T) {
+	var calls int32
+	server := newHTTPServer(&Config{
+		Enabled: true,
+		TTL:     "1m",
+		Paths:   []string{"/products/:id"},
+	}, &calls)
+
+	rec := serveRequest(server, http.MethodGet, "/products/1")
+	assert.Equal(t, "/products/1-1", rec.Body.String())
+	assert.Equal(t, CacheMiss, rec.Header().Get("X-Cache"))
+
+	rec = serveRequest(server, http.MethodGet, "/products/1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "/products/1-1", rec.Body.String())
+	assert.Equal(t, "text/plain", rec.Header().Get("Content-Type"))
+	assert.Equal(t, CacheHit, rec.Header().Get("X-Cache"))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestHTTPMiddlewareShouldPassUncachedRequestsThrough(t *testing.T) {
+	var calls int32
+	server := newHTTPServer(&Config{
+		Enabled: true,
+		TTL:     "1m",
+		Paths:   []string{"/products/:id"},
+	}, &calls)
+
+	serveRequest(server, http.MethodPost, "/products/1")
+	rec := serveRequest(server, http.MethodGet, "/products/")
+
+	assert.Equal(t, "/products/-2", rec.Body.String())
+	assert.Empty(t, rec.Header().Get("X-Cache"))
+}
+
+func TestHTTPMiddlewareShouldPurgeAndWriteInfo(t *testing.T) {
+	var calls int32
+	server := newHTTPServer(&Config{
+		Enabled:       true,
+		TTL:           "1m",
+		Paths:         []string{"/products/:id"},
+		PurgePath:     "/purge",
+		CacheInfoPath: "/cache/info",
+		AdminAuth: &AdminAuth{
+			AuthorizeRequest: func(req *http.Request) bool {
+				return req.Header.Get("X-Admin") == "yes"
+			},
+		},
+	}, &calls)
+	serveRequest(server, http.MethodGet, "/products/1")
+
+	assert.Equal(t, http.StatusForbidden, serveRequest(server, "PURGE", "/products/1").Code)
+	req := httptest.NewRequest("PURGE", "/products/1", nil)
+	req.Header.Set("X-Admin", "yes")
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "/products/1-2", serveRequest(server, http.MethodGet, "/products/1").Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/cache/info", nil)
+	req.Header.Set("X-Admin", "yes")
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	var info map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &info))
+	assert.Equal(t, "application/json; charset=UTF-8", rec.Header().Get("Content-Type"))
+	assert.Equal(t, float64(1), info["counters"].(map[string]interface{})[MetricPurges])
+}
+
+func TestHTTPMiddlewareShouldDenyAdminRequestIfEchoAuthorizeIsSet(t *testing.T) {
+	var calls int32
+	server := newHTTPServer(&Config{
+		Enabled:   true,
+		TTL:       "1m",
+		Paths:     []string{"/products/:id"},
+		PurgePath: "/purge",
+		AdminAuth: &AdminAuth{
+			Authorize: func(ctx echo4.Context) bool {
+				return true
+			},
+		},
+	}, &calls)
+
+	assert.Equal(t, http.StatusForbidden, serveRequest(server, "PURGE", "/purge").Code)
+}
+
+func TestHTTPMiddlewareShouldServeStaleWhileRevalidate(t *testing.T) {
+	var calls int32
+	server := newHTTPServer(&Config{
+		Enabled: true,
+		Rules: []Rule{
+			{Path: "/products/:id", TTL: "10ms", StaleWhileRevalidate: "1m"},
+		},
+	}, &calls)
+
+	assert.Equal(t, "/products/7-1", serveRequest(server, http.MethodGet, "/products/7").Body.String())
+	time.Sleep(20 * time.Millisecond)
+	rec := serveRequest(server, http.MethodGet, "/products/7")
+	assert.Equal(t, "/products/7-1", rec.Body.String())
+	assert.Equal(t, CacheStale, rec.Header().Get("X-Cache"))
+	assert.Eventually(t, func() bool {
+		return serveRequest(server, http.MethodGet, "/products/7").Body.String() == "/products/7-2"
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestTryWriteShouldWriteFreshContent(t *testing.T) {
+	cm := NewCacheManager(&Config{Enabled: true, TTL: "1m", Paths: []string{"/products/:id"}}, newMemoryCache())
+	server := HTTPMiddlewareWithManager(cm)(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		writer.Write([]byte("product"))
+	}))
+
+	rec := httptest.NewRecorder()
+	assert.False(t, cm.TryWrite(rec, httptest.NewRequest(http.MethodGet, "/products/1", nil)))
+	serveRequest(server, http.MethodGet, "/products/1")
+
+	rec = httptest.NewRecorder()
+	assert.True(t, cm.TryWrite(rec, httptest.NewRequest(http.MethodGet, "/products/1", nil)))
+	assert.Equal(t, "product", rec.Body.String())
+	assert.Equal(t, CacheHit, rec.Header().Get("X-Cache"))
+}
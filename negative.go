@@ -0,0 +1,43 @@
+package cacheman
+
+import (
+	"net/http"
+
+	echo3 "github.com/labstack/echo"
+	echo4 "github.com/labstack/echo/v4"
+)
+
+// defaultNegativeTTL is TTL of negative status without its own TTL
+var defaultNegativeTTL = "10s"
+
+// negative returns true if response status means failure, e.g. 404 or 5xx
+func negative(status int) bool {
+	return status >= http.StatusBadRequest
+}
+
+// renderNegativeV3 renders error of negatively cacheable status through echo error handler, see renderNegativeV4
+func (c *Manager) renderNegativeV3(ctx echo3.Context, e error) error {
+	httpError, ok := e.(*echo3.HTTPError)
+	if !ok {
+		return e
+	}
+	if _, cacheable := c.NegativeStatuses[httpError.Code]; !cacheable {
+		return e
+	}
+	ctx.Error(e)
+	return nil
+}
+
+// renderNegativeV4 renders error of negatively cacheable status through echo error handler,
+// so the response can be captured and stored. Other errors are returned as they are.
+func (c *Manager) renderNegativeV4(ctx echo4.Context, e error) error {
+	httpError, ok := e.(*echo4.HTTPError)
+	if !ok {
+		return e
+	}
+	if _, cacheable := c.NegativeStatuses[httpError.Code]; !cacheable {
+		return e
+	}
+	ctx.Error(e)
+	return nil
+}
@@ -0,0 +1,91 @@
+package cacheman
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	echo4 "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNegativeStatusesShouldUseNegativeTTL(t *testing.T) {
+	cm := NewCacheManager(&Config{
+		NegativeStatuses: map[int]string{
+			http.StatusNotFound: "",
+			http.StatusGone:     "1h",
+		},
+		NegativeTTL: "30s",
+	}, nil)
+
+	ttl, cacheable := cm.statusTTL(http.StatusNotFound, time.Minute)
+	assert.True(t, cacheable)
+	assert.Equal(t, 30*time.Second, ttl)
+
+	ttl, cacheable = cm.statusTTL(http.StatusGone, time.Minute)
+	assert.True(t, cacheable)
+	assert.Equal(t, time.Hour, ttl)
+}
+
+func TestNegativeTTLShouldDefaultTo10Seconds(t *testing.T) {
+	cm := NewCacheManager(&Config{
+		NegativeStatuses: map[int]string{http.StatusNotFound: ""},
+	}, nil)
+
+	assert.Equal(t, 10*time.Second, cm.NegativeStatuses[http.StatusNotFound])
+}
+
+func TestMiddlewareV4ShouldCacheReturnedNotFoundError(t *testing.T) {
+	calls := 0
+	cache := newMemoryCache()
+	server := echo4.New()
+	server.Use(MiddlewareV4(&Config{
+		Enabled:          true,
+		TTL:              "1m",
+		Paths:            []string{"/products/:id"},
+		NegativeStatuses: map[int]string{http.StatusNotFound: ""},
+	}, cache))
+	server.GET("/products/:id", func(ctx echo4.Context) error {
+		calls++
+		return echo4.NewHTTPError(http.StatusNotFound, "no such product")
+	})
+
+	for index := 0; index < 2; index++ {
+		req := httptest.NewRequest(http.MethodGet, "/products/404", nil)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Contains(t, rec.Body.String(), "no such product")
+	}
+	assert.Equal(t, 1, calls)
+
+	var content Content
+	assert.NoError(t, BinaryCodec{}.Decode(cache.entries["/products/404"], &content))
+	assert.True(t, content.Negative)
+}
+
+func TestMiddlewareV4ShouldNotCacheErrorOfOtherStatus(t *testing.T) {
+	cache := new(MockTTLCache)
+	cache.On("Get", mock.AnythingOfType("string")).Return([]byte{}, errors.New("miss"))
+	server := echo4.New()
+	server.Use(MiddlewareV4(&Config{
+		Enabled:          true,
+		TTL:              "1m",
+		Paths:            []string{"/products/:id"},
+		NegativeStatuses: map[int]string{http.StatusNotFound: ""},
+	}, cache))
+	server.GET("/products/:id", func(ctx echo4.Context) error {
+		return echo4.NewHTTPError(http.StatusServiceUnavailable)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/products/1", nil)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	cache.AssertNotCalled(t, "SetWithTTL", mock.Anything, mock.Anything, mock.Anything)
+}
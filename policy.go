@@ -0,0 +1,109 @@
+package cacheman
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// PolicyForceCache caches response regardless of Cache-Control headers
+	PolicyForceCache string = "force"
+	// PolicyRespectHeaders follows Cache-Control headers of request and response
+	PolicyRespectHeaders string = "respect"
+)
+
+// Policy decides whether request can be served from cache and how long response can be stored
+type Policy interface {
+	// CanLookup returns true if request can be served from cache
+	CanLookup(req *http.Request) bool
+	// StoreTTL returns TTL of response to be stored, or false if response must not be stored.
+	// ttl is TTL configured for the route.
+	StoreTTL(header http.Header, ttl time.Duration) (time.Duration, bool)
+}
+
+// NewPolicy creates policy from its name. Unknown name falls back to PolicyForceCache.
+func NewPolicy(name string) Policy {
+	if name == PolicyRespectHeaders {
+		return &RespectHeadersPolicy{}
+	}
+	return &ForceCachePolicy{}
+}
+
+// ForceCachePolicy always serves from cache and stores response with route TTL
+type ForceCachePolicy struct{}
+
+// CanLookup always returns true
+func (p *ForceCachePolicy) CanLookup(req *http.Request) bool {
+	return true
+}
+
+// StoreTTL always returns route TTL
+func (p *ForceCachePolicy) StoreTTL(header http.Header, ttl time.Duration) (time.Duration, bool) {
+	return ttl, true
+}
+
+// RespectHeadersPolicy follows Cache-Control and Pragma headers
+type RespectHeadersPolicy struct{}
+
+// CanLookup returns false if request asks for a fresh response with Cache-Control: no-cache,
+// Cache-Control: max-age=0 or Pragma: no-cache
+func (p *RespectHeadersPolicy) CanLookup(req *http.Request) bool {
+	directives := parseCacheControl(req.Header)
+	if _, ok := directives["no-cache"]; ok {
+		return false
+	}
+	if maxAge, ok := directives["max-age"]; ok && maxAge == "0" {
+		return false
+	}
+	for _, pragma := range req.Header.Values("Pragma") {
+		if strings.EqualFold(strings.TrimSpace(pragma), "no-cache") {
+			return false
+		}
+	}
+	return true
+}
+
+// StoreTTL refuses response with Cache-Control: no-store, private or no-cache.
+// TTL is taken from s-maxage, then max-age, then route TTL.
+func (p *RespectHeadersPolicy) StoreTTL(header http.Header, ttl time.Duration) (time.Duration, bool) {
+	directives := parseCacheControl(header)
+	for _, directive := range []string{"no-store", "private", "no-cache"} {
+		if _, ok := directives[directive]; ok {
+			return 0, false
+		}
+	}
+	for _, directive := range []string{"s-maxage", "max-age"} {
+		if value, ok := directives[directive]; ok {
+			seconds, e := strconv.Atoi(value)
+			if e != nil || seconds <= 0 {
+				return 0, false
+			}
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+	return ttl, true
+}
+
+// parseCacheControl parses Cache-Control header into directives and their values
+func parseCacheControl(header http.Header) map[string]string {
+	directives := map[string]string{}
+	for _, value := range header.Values("Cache-Control") {
+		for _, part := range strings.Split(value, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			name, argument := part, ""
+			if index := strings.Index(part, "="); index >= 0 {
+				name, argument = part[:index], strings.Trim(strings.TrimSpace(part[index+1:]), `"`)
+			}
+			name = strings.ToLower(strings.TrimSpace(name))
+			if _, exists := directives[name]; !exists {
+				directives[name] = argument
+			}
+		}
+	}
+	return directives
+}
@@ -0,0 +1,161 @@
+package cacheman
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	echo4 "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNewPolicyShouldFallBackToForceCache(t *testing.T) {
+	assert.IsType(t, &ForceCachePolicy{}, NewPolicy(""))
+	assert.IsType(t, &ForceCachePolicy{}, NewPolicy(PolicyForceCache))
+	assert.IsType(t, &RespectHeadersPolicy{}, NewPolicy(PolicyRespectHeaders))
+}
+
+func TestForceCachePolicyShouldIgnoreHeaders(t *testing.T) {
+	policy := &ForceCachePolicy{}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Cache-Control", "no-cache")
+	header := http.Header{}
+	header.Set("Cache-Control", "no-store")
+
+	ttl, storable := policy.StoreTTL(header, time.Minute)
+
+	assert.True(t, policy.CanLookup(req))
+	assert.True(t, storable)
+	assert.Equal(t, time.Minute, ttl)
+}
+
+func TestRespectHeadersPolicyShouldBypassLookupOnRequestNoCache(t *testing.T) {
+	policy := &RespectHeadersPolicy{}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Cache-Control", "No-Cache")
+
+	assert.False(t, policy.CanLookup(req))
+}
+
+func TestRespectHeadersPolicyShouldBypassLookupOnPragmaNoCache(t *testing.T) {
+	policy := &RespectHeadersPolicy{}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Pragma", "no-cache")
+
+	assert.False(t, policy.CanLookup(req))
+}
+
+func TestRespectHeadersPolicyShouldLookupPlainRequest(t *testing.T) {
+	policy := &RespectHeadersPolicy{}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	assert.True(t, policy.CanLookup(req))
+}
+
+func TestRespectHeadersPolicyShouldNotStoreNoStorePrivateOrNoCache(t *testing.T) {
+	policy := &RespectHeadersPolicy{}
+	for _, value := range []string{"no-store", "private, max-age=60", "public, no-cache"} {
+		header := http.Header{}
+		header.Set("Cache-Control", value)
+
+		_, storable := policy.StoreTTL(header, time.Minute)
+
+		assert.False(t, storable, value)
+	}
+}
+
+func TestRespectHeadersPolicyShouldPreferSMaxAgeOverMaxAge(t *testing.T) {
+	policy := &RespectHeadersPolicy{}
+	header := http.Header{}
+	header.Set("Cache-Control", "public, max-age=60, s-maxage=300")
+
+	ttl, storable := policy.StoreTTL(header, time.Minute)
+
+	assert.True(t, storable)
+	assert.Equal(t, 300*time.Second, ttl)
+}
+
+func TestRespectHeadersPolicyShouldUseMaxAge(t *testing.T) {
+	policy := &RespectHeadersPolicy{}
+	header := http.Header{}
+	header.Set("Cache-Control", "max-age=\"30\"")
+
+	ttl, storable := policy.StoreTTL(header, time.Minute)
+
+	assert.True(t, storable)
+	assert.Equal(t, 30*time.Second, ttl)
+}
+
+func TestRespectHeadersPolicyShouldNotStoreZeroMaxAge(t *testing.T) {
+	policy := &RespectHeadersPolicy{}
+	header := http.Header{}
+	header.Set("Cache-Control", "max-age=0")
+
+	_, storable := policy.StoreTTL(header, time.Minute)
+
+	assert.False(t, storable)
+}
+
+func TestRespectHeadersPolicyShouldUseRouteTTLWithoutCacheControl(t *testing.T) {
+	policy := &RespectHeadersPolicy{}
+
+	ttl, storable := policy.StoreTTL(http.Header{}, time.Minute)
+
+	assert.True(t, storable)
+	assert.Equal(t, time.Minute, ttl)
+}
+
+func TestMiddlewareV4WithRespectHeadersPolicyShouldNotStorePrivateResponse(t *testing.T) {
+	cache := new(MockTTLCache)
+	cache.On("Get", mock.AnythingOfType("string")).Return([]byte{}, errors.New("miss"))
+	conf := &Config{
+		Enabled: true,
+		TTL:     "1m",
+		Paths:   []string{"/me"},
+		Policy:  PolicyRespectHeaders,
+	}
+	server := echo4.New()
+	server.Use(MiddlewareV4(conf, cache))
+	server.GET("/me", func(ctx echo4.Context) error {
+		ctx.Response().Header().Set("Cache-Control", "private")
+		return ctx.String(http.StatusOK, "me")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	assert.Equal(t, "me", rec.Body.String())
+	cache.AssertNotCalled(t, "SetWithTTL", mock.Anything, mock.Anything, mock.Anything)
+	cache.AssertNotCalled(t, "Set", mock.Anything, mock.Anything)
+}
+
+func TestMiddlewareV4WithRespectHeadersPolicyShouldBypassLookupOnRequestNoCache(t *testing.T) {
+	cache := new(MockTTLCache)
+	cache.On("SetWithTTL", "/news", mock.AnythingOfType("[]uint8"), 30*time.Second).Return(nil)
+	cache.On("Delete", "/news#vary").Return(nil)
+	conf := &Config{
+		Enabled: true,
+		TTL:     "1m",
+		Paths:   []string{"/news"},
+		Policy:  PolicyRespectHeaders,
+	}
+	server := echo4.New()
+	server.Use(MiddlewareV4(conf, cache))
+	server.GET("/news", func(ctx echo4.Context) error {
+		ctx.Response().Header().Set("Cache-Control", "public, max-age=30")
+		return ctx.String(http.StatusOK, "news")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/news", nil)
+	req.Header.Set("Cache-Control", "no-cache")
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	assert.Equal(t, "news", rec.Body.String())
+	cache.AssertNotCalled(t, "Get", mock.Anything)
+	cache.AssertNumberOfCalls(t, "SetWithTTL", 1)
+}
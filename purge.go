@@ -0,0 +1,207 @@
+package cacheman
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	echo3 "github.com/labstack/echo"
+	echo4 "github.com/labstack/echo/v4"
+)
+
+// ErrPurgeNotSupported is returned when cache does not support the requested kind of purge
+var ErrPurgeNotSupported = errors.New("purge is not supported by cache")
+
+// tagHeaders are response headers carrying tags of content
+var tagHeaders = []string{"Surrogate-Key", "Cache-Tag"}
+
+// extractTags removes tag headers from header and returns their tags
+func extractTags(header http.Header) []string {
+	tags := []string{}
+	for _, tagHeader := range tagHeaders {
+		tags = append(tags, splitTags(header.Values(tagHeader)...)...)
+		header.Del(tagHeader)
+	}
+	return tags
+}
+
+// tagKey returns key of tag index
+func tagKey(tag string) string {
+	return "tag:" + tag
+}
+
+// PurgeKey deletes cached content of cache key with all its variants, i.e. by Vary headers or POST body
+func (c *Manager) PurgeKey(key string) error {
+	c.Logger.Info("Cache purges key", "key", key)
+	c.count(MetricPurges, "")
+	// Key may not be in cache, so error of deleting it is not an error of purging
+	c.Cache.Delete(c.createKey(key))
+	c.Cache.Delete(c.createKey(varyIndexKey(key)))
+	if cache, ok := c.Cache.(PrefixDeleter); ok {
+		return cache.DeletePrefix(c.createKey(key + "#"))
+	}
+	return nil
+}
+
+// PurgePrefix deletes cached content of every cache key starting with prefix
+func (c *Manager) PurgePrefix(prefix string) error {
+	c.Logger.Info("Cache purges prefix", "prefix", prefix)
+	c.count(MetricPurges, "")
+	cache, ok := c.Cache.(PrefixDeleter)
+	if !ok {
+		return ErrPurgeNotSupported
+	}
+	return cache.DeletePrefix(c.createKey(prefix))
+}
+
+// PurgePattern deletes cached content of every cache key matching glob pattern
+func (c *Manager) PurgePattern(pattern string) error {
+	c.Logger.Info("Cache purges pattern", "pattern", pattern)
+	c.count(MetricPurges, "")
+	cache, ok := c.Cache.(PatternDeleter)
+	if !ok {
+		return ErrPurgeNotSupported
+	}
+	return cache.DeletePattern(escapeGlob(c.createKey("")) + pattern)
+}
+
+// PurgeTags deletes cached content tagged with any of tags
+func (c *Manager) PurgeTags(tags ...string) error {
+	c.Logger.Info("Cache purges tags", "tags", strings.Join(tags, " "))
+	c.count(MetricPurges, "")
+	cache, ok := c.Cache.(TagIndexer)
+	if !ok {
+		return ErrPurgeNotSupported
+	}
+	tagKeys := make([]string, len(tags))
+	for index, tag := range tags {
+		tagKeys[index] = c.createKey(tagKey(tag))
+	}
+	return cache.DeleteTags(tagKeys...)
+}
+
+// tag records tags of cached content of cache key
+func (c *Manager) tag(key string, tags []string, ttl time.Duration) error {
+	cache, ok := c.Cache.(TagIndexer)
+	if !ok || len(tags) == 0 {
+		return nil
+	}
+	tagKeys := make([]string, len(tags))
+	for index, tag := range tags {
+		tagKeys[index] = c.createKey(tagKey(tag))
+	}
+	return cache.AddTags(c.createKey(key), tagKeys, ttl)
+}
+
+// ServePurge handles PURGE request.
+// PURGE to PurgePath purges everything, or by tag, prefix or pattern given in query.
+// Tags can also be given in Surrogate-Key or Cache-Tag header.
+// PURGE to other URI purges cached content of that URI, or URIs matching it if it contains * wildcard.
+func (c *Manager) ServePurge(writer http.ResponseWriter, req *http.Request) {
+	e := c.purge(req)
+	switch {
+	case e == nil:
+		writer.WriteHeader(http.StatusOK)
+	case errors.Is(e, ErrPurgeNotSupported):
+		writer.WriteHeader(http.StatusNotImplemented)
+	default:
+		c.Logger.Error("Cache fails to purge", "uri", req.RequestURI, "error", e)
+		writer.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// PurgeV3 handles PURGE request for echo V3, see ServePurge
+func (c *Manager) PurgeV3(ctx echo3.Context) {
+	c.ServePurge(ctx.Response(), ctx.Request())
+}
+
+// PurgeV4 handles PURGE request for echo V4, see ServePurge
+func (c *Manager) PurgeV4(ctx echo4.Context) {
+	c.ServePurge(ctx.Response(), ctx.Request())
+}
+
+// purge purges cache as requested by PURGE request
+func (c *Manager) purge(req *http.Request) error {
+	if req.URL.Path != c.PurgePath {
+		uri := RequestURIKey(req)
+		if !strings.ContainsAny(uri, "*") {
+			if !c.keyable(req) {
+				return ErrPurgeNotSupported
+			}
+			return c.PurgeKey(c.baseKey(req))
+		}
+		if prefix := strings.TrimSuffix(uri, "*"); !strings.ContainsAny(prefix, "*") {
+			return c.PurgePrefix(prefix)
+		}
+		return c.PurgePattern(uri)
+	}
+
+	query := req.URL.Query()
+	tags := splitTags(query["tag"]...)
+	for _, tagHeader := range tagHeaders {
+		tags = append(tags, splitTags(req.Header.Values(tagHeader)...)...)
+	}
+	if len(tags) > 0 {
+		return c.PurgeTags(tags...)
+	}
+	if prefix := query.Get("prefix"); prefix != "" {
+		return c.PurgePrefix(prefix)
+	}
+	if pattern := query.Get("pattern"); pattern != "" {
+		return c.PurgePattern(pattern)
+	}
+	if key := query.Get("key"); key != "" {
+		return c.PurgeKey(key)
+	}
+	return c.Purge()
+}
+
+// splitTags splits space or comma separated tags
+func splitTags(values ...string) []string {
+	tags := []string{}
+	for _, value := range values {
+		tags = append(tags, strings.FieldsFunc(value, func(r rune) bool {
+			return r == ' ' || r == ','
+		})...)
+	}
+	return tags
+}
+
+// escapeGlob escapes glob special characters, so s matches only itself
+func escapeGlob(s string) string {
+	var builder strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			builder.WriteRune('\\')
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// compileGlob converts glob pattern into regular expression.
+// * matches any sequence of characters, ? matches any single character and \ escapes the next character.
+func compileGlob(pattern string) *regexp.Regexp {
+	var builder strings.Builder
+	builder.WriteString("^")
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			builder.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '*':
+			builder.WriteString(".*")
+		case r == '?':
+			builder.WriteString(".")
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	builder.WriteString("$")
+	return regexp.MustCompile(builder.String())
+}
@@ -0,0 +1,177 @@
+package cacheman
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	echo4 "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEscapeGlob(t *testing.T) {
+	assert.Equal(t, `/search\?q=\*`, escapeGlob("/search?q=*"))
+}
+
+func TestCompileGlob(t *testing.T) {
+	matcher := compileGlob(`/products/*/reviews?\*`)
+
+	assert.True(t, matcher.MatchString("/products/1/reviews/*"))
+	assert.True(t, matcher.MatchString("/products/1/2/reviews?*"))
+	assert.False(t, matcher.MatchString("/products/1/reviews/x"))
+}
+
+func TestSplitTags(t *testing.T) {
+	assert.Equal(t, []string{"a", "b", "c"}, splitTags("a b", "c,"))
+}
+
+func TestPurgeNotSupportedByCache(t *testing.T) {
+	cm := NewCacheManager(&Config{}, newMemoryCache())
+
+	assert.Equal(t, ErrPurgeNotSupported, cm.PurgePrefix("/products"))
+	assert.Equal(t, ErrPurgeNotSupported, cm.PurgePattern("/products/*"))
+	assert.Equal(t, ErrPurgeNotSupported, cm.PurgeTags("product"))
+}
+
+func cached(cache CacheInterface, key string) bool {
+	_, e := cache.Get(key)
+	return e == nil
+}
+
+func TestPurgeShouldDeleteMatchingContent(t *testing.T) {
+	cases := []struct {
+		name   string
+		uri    string
+		purged []string
+		kept   []string
+	}{
+		{"URI", "/products/1", []string{"/products/1"}, []string{"/products/2", "/categories/1"}},
+		{"URIWithTrailingWildcard", "/products/*", []string{"/products/1", "/products/2"}, []string{"/categories/1"}},
+		{"Pattern", "/purge?pattern=/*/1", []string{"/products/1", "/categories/1"}, []string{"/products/2"}},
+		{"Tag", "/purge?tag=products", []string{"/products/1", "/products/2"}, []string{"/categories/1"}},
+		{"Everything", "/purge", []string{"/products/1", "/products/2", "/categories/1"}, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			conf := &Config{
+				Enabled:   true,
+				TTL:       "1m",
+				Namespace: "shop",
+				PurgePath: "/purge",
+				Rules: []Rule{
+					{Path: "/products/:id", Tags: []string{"products"}},
+					{Path: "/categories/:id"},
+				},
+			}
+			cache, e := NewBigCache(conf)
+			assert.NoError(t, e)
+			server := echo4.New()
+			server.Use(MiddlewareV4(conf, cache))
+			server.GET("/products/:id", func(ctx echo4.Context) error {
+				return ctx.String(http.StatusOK, "product")
+			})
+			server.GET("/categories/:id", func(ctx echo4.Context) error {
+				return ctx.String(http.StatusOK, "category")
+			})
+			for _, uri := range []string{"/products/1", "/products/2", "/categories/1"} {
+				serveRequest(server, http.MethodGet, uri)
+			}
+
+			assert.Equal(t, http.StatusOK, serveRequest(server, "PURGE", tc.uri).Code)
+
+			for _, uri := range tc.purged {
+				assert.False(t, cached(cache, "shop."+uri), uri)
+			}
+			for _, uri := range tc.kept {
+				assert.True(t, cached(cache, "shop."+uri), uri)
+			}
+		})
+	}
+}
+
+func TestPurgeShouldAnswerNotImplementedIfCacheDoesNotSupportIt(t *testing.T) {
+	server := echo4.New()
+	server.Use(MiddlewareV4(&Config{Enabled: true, PurgePath: "/purge"}, newMemoryCache()))
+
+	assert.Equal(t, http.StatusNotImplemented, serveRequest(server, "PURGE", "/products/*").Code)
+}
+
+func TestSurrogateKeyShouldTagContentAndNotBeSentToClient(t *testing.T) {
+	conf := &Config{
+		Enabled:   true,
+		TTL:       "1m",
+		PurgePath: "/purge",
+		Paths:     []string{"/products/:id"},
+	}
+	cache, _ := NewBigCache(conf)
+	cm := NewCacheManager(conf, cache)
+	server := echo4.New()
+	server.Use(MiddlewareV4WithManager(cm))
+	server.GET("/products/:id", func(ctx echo4.Context) error {
+		ctx.Response().Header().Set("Surrogate-Key", "product-"+ctx.Param("id")+" products")
+		return ctx.String(http.StatusOK, "product")
+	})
+	for _, uri := range []string{"/products/1", "/products/2"} {
+		rec := serveRequest(server, http.MethodGet, uri)
+		assert.Empty(t, rec.Header().Get("Surrogate-Key"))
+	}
+
+	req := httptest.NewRequest("PURGE", "/purge", nil)
+	req.Header.Set("Surrogate-Key", "product-1")
+	server.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.False(t, cached(cache, "/products/1"))
+	assert.True(t, cached(cache, "/products/2"))
+
+	assert.NoError(t, cm.PurgeTags("products"))
+
+	assert.False(t, cached(cache, "/products/2"))
+}
+
+func TestMemcachedShouldNotSupportPrefixOrPatternPurge(t *testing.T) {
+	cache, _ := NewMemcached(&Config{Server: "127.0.0.1:0", Namespace: "shop"})
+	cm := NewCacheManager(&Config{Namespace: "shop"}, cache)
+
+	assert.Equal(t, ErrPurgeNotSupported, cm.PurgePrefix("/products"))
+	assert.Equal(t, ErrPurgeNotSupported, cm.PurgePattern("/products/*"))
+}
+
+func TestTagIndexShouldKeepExpiryAndUniqueKeys(t *testing.T) {
+	expiresAt := time.Now().Add(time.Minute).Unix()
+	index := newTagIndex("tag:products", expiresAt, []string{"shop./products/1", "shop./products/2"})
+	index.Value = append(index.Value, "\nshop./products/1"...)
+
+	parsedExpiresAt, keys := parseTagIndex(index.Value)
+
+	assert.Equal(t, expiresAt, parsedExpiresAt)
+	assert.Equal(t, []string{"shop./products/1", "shop./products/2"}, keys)
+	assert.InDelta(t, 60, index.Expiration, 1)
+}
+
+func TestMemcachedExpirationShouldRoundUpToSecond(t *testing.T) {
+	assert.Equal(t, int32(1), memcachedExpiration(10*time.Millisecond))
+	assert.Equal(t, int32(2), memcachedExpiration(1500*time.Millisecond))
+	assert.Equal(t, int32(1), memcachedExpiration(-time.Second))
+}
+
+func TestMemcachedExpirationShouldBeUnixTimeBeyond30Days(t *testing.T) {
+	assert.Equal(t, int32(30*24*60*60), memcachedExpiration(30*24*time.Hour))
+	assert.InDelta(t, time.Now().Add(31*24*time.Hour).Unix(), memcachedExpiration(31*24*time.Hour), 1)
+}
+
+func TestBigCacheShouldSweepMissingKeysFromTagIndex(t *testing.T) {
+	cache, e := NewBigCache(&Config{TTL: "1m"})
+	assert.NoError(t, e)
+	for index := 0; index < minTagSweep-1; index++ {
+		assert.NoError(t, cache.AddTags(fmt.Sprintf("/deleted/%d", index), []string{"products"}, time.Minute))
+	}
+	assert.NoError(t, cache.Set("/products/1", []byte("product")))
+
+	assert.NoError(t, cache.AddTags("/products/1", []string{"products"}, time.Minute))
+
+	assert.Len(t, cache.tags["products"], 1)
+	assert.Contains(t, cache.tags["products"], "/products/1")
+	assert.Equal(t, 1, cache.tagged)
+}
@@ -0,0 +1,138 @@
+package cacheman
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	echo3 "github.com/labstack/echo"
+	echo4 "github.com/labstack/echo/v4"
+)
+
+// revalidatable returns true if stale content can be served while it is refreshed in background
+func revalidatable(content *Content, rule *ComparableRule, now time.Time) bool {
+	return rule.StaleWhileRevalidate > 0 && content.StaleFor(now) <= rule.StaleWhileRevalidate
+}
+
+// errorServable returns true if stale content can be served in place of failed response
+func errorServable(content *Content, rule *ComparableRule, now time.Time) bool {
+	return rule.StaleIfError > 0 && content.StaleFor(now) <= rule.StaleIfError
+}
+
+// failed returns true if handler result should be replaced by stale content
+func failed(e error, status int) bool {
+	return e != nil || status >= http.StatusInternalServerError
+}
+
+// refresh runs handler again in background with a copy of request and stores its response.
+// Only one refresh runs at a time for each cache key.
+func (c *Manager) refresh(req *http.Request, background backgroundFunc, rule *ComparableRule, cacheKey string) {
+	if _, running := c.refreshing.LoadOrStore(cacheKey, true); running {
+		return
+	}
+
+	// Request is done once it is served, so refresh works on its own copy,
+	// with cache key built beforehand since echo context is recycled too
+	refreshReq := req.Clone(context.Background())
+	if build, ok := keyBuilder(req); ok {
+		key := build()
+		refreshReq = withKeyBuilder(refreshReq, func() string {
+			return key
+		})
+	}
+	req = refreshReq
+	if req.GetBody != nil {
+		req.Body, _ = req.GetBody()
+	}
+	req.Header.Del("If-None-Match")
+	req.Header.Del("If-Modified-Since")
+	interceptor := NewBufferedInterceptor(&discardWriter{header: http.Header{}})
+	interceptor.SetMaxBodySize(c.MaxBodySize)
+	run := background(interceptor, req)
+
+	go func() {
+		defer c.refreshing.Delete(cacheKey)
+		c.Logger.Debug("Cache refreshes", "key", cacheKey, "route", rule.Rule.Path)
+		e := run()
+		if failed(e, interceptor.Status()) {
+			c.Logger.Warn("Cache refresh fails", "key", cacheKey, "route", rule.Rule.Path, "status", interceptor.Status(), "error", e)
+			return
+		}
+		c.store(req, rule, interceptor)
+	}()
+}
+
+// refreshContext is echo context of background refresh.
+// Route path and parameters are copied from original context, which is recycled by echo once request completes.
+type refreshContext struct {
+	echo4.Context
+	path   string
+	names  []string
+	values []string
+}
+
+func (c *refreshContext) Path() string {
+	return c.path
+}
+
+func (c *refreshContext) Param(name string) string {
+	for index, paramName := range c.names {
+		if paramName == name && index < len(c.values) {
+			return c.values[index]
+		}
+	}
+	return ""
+}
+
+func (c *refreshContext) ParamNames() []string {
+	return c.names
+}
+
+func (c *refreshContext) ParamValues() []string {
+	return c.values
+}
+
+// refreshContextV3 is echo V3 context of background refresh, see refreshContext
+type refreshContextV3 struct {
+	echo3.Context
+	path   string
+	names  []string
+	values []string
+}
+
+func (c *refreshContextV3) Path() string {
+	return c.path
+}
+
+func (c *refreshContextV3) Param(name string) string {
+	for index, paramName := range c.names {
+		if paramName == name && index < len(c.values) {
+			return c.values[index]
+		}
+	}
+	return ""
+}
+
+func (c *refreshContextV3) ParamNames() []string {
+	return c.names
+}
+
+func (c *refreshContextV3) ParamValues() []string {
+	return c.values
+}
+
+// discardWriter is response writer of background refresh, nothing is sent anywhere
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *discardWriter) WriteHeader(statusCode int) {
+}
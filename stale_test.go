@@ -0,0 +1,170 @@
+package cacheman
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	echo4 "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentWithoutFreshUntilShouldBeFresh(t *testing.T) {
+	content := &Content{}
+
+	assert.True(t, content.Fresh(time.Now()))
+	assert.Equal(t, time.Duration(0), content.StaleFor(time.Now()))
+}
+
+func TestContentStaleFor(t *testing.T) {
+	now := time.Now()
+	content := &Content{FreshUntil: now.Add(-time.Minute).UnixNano()}
+
+	assert.False(t, content.Fresh(now))
+	assert.Equal(t, time.Minute, content.StaleFor(now))
+}
+
+func TestComparableRuleStoreTTLShouldAddLongestStaleWindow(t *testing.T) {
+	rule := &ComparableRule{StaleWhileRevalidate: time.Minute, StaleIfError: time.Hour}
+
+	assert.Equal(t, time.Hour+time.Minute, rule.StoreTTL(time.Minute))
+}
+
+func TestMaxStoreTTLShouldIncludeStaleWindowsAndStatuses(t *testing.T) {
+	conf := &Config{
+		TTL:               "1s",
+		StaleIfError:      "1h",
+		CacheableStatuses: map[int]string{http.StatusOK: "", http.StatusMovedPermanently: "10m"},
+		Rules: []Rule{
+			{Path: "/stock/:id", TTL: "5m", StaleWhileRevalidate: "2h"},
+		},
+	}
+
+	assert.Equal(t, 10*time.Minute+2*time.Hour, conf.maxStoreTTL())
+}
+
+func TestMaxStoreTTLShouldKeepTTLForRevalidation(t *testing.T) {
+	conf := &Config{TTL: "1m"}
+
+	assert.Equal(t, 2*time.Minute, conf.maxStoreTTL())
+}
+
+func TestMatchRuleShouldUseRuleStaleWindows(t *testing.T) {
+	conf := &Config{
+		TTL:                  "1m",
+		StaleWhileRevalidate: "30s",
+		StaleIfError:         "1h",
+		Rules: []Rule{
+			{Path: "/stock/:id", StaleIfError: "5m"},
+		},
+		Paths: []string{"/products/:id"},
+	}
+	cm := NewCacheManager(conf, nil)
+
+	rule, _ := cm.MatchRule("/stock/1")
+	assert.Equal(t, 30*time.Second, rule.StaleWhileRevalidate)
+	assert.Equal(t, 5*time.Minute, rule.StaleIfError)
+
+	rule, _ = cm.MatchRule("/products/1")
+	assert.Equal(t, 30*time.Second, rule.StaleWhileRevalidate)
+	assert.Equal(t, time.Hour, rule.StaleIfError)
+}
+
+func TestMiddlewareV4ShouldServeStaleWhileRevalidate(t *testing.T) {
+	var calls int32
+	conf := &Config{
+		Enabled: true,
+		Rules: []Rule{
+			{Path: "/stock/:id", TTL: "10ms", StaleWhileRevalidate: "1m"},
+		},
+	}
+	server := echo4.New()
+	server.Use(MiddlewareV4(conf, newMemoryCache()))
+	server.GET("/stock/:id", func(ctx echo4.Context) error {
+		call := atomic.AddInt32(&calls, 1)
+		return ctx.String(http.StatusOK, fmt.Sprintf("%s-%d", ctx.Param("id"), call))
+	})
+
+	request := func() string {
+		req := httptest.NewRequest(http.MethodGet, "/stock/7", nil)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+
+	assert.Equal(t, "7-1", request())
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, "7-1", request())
+	assert.Eventually(t, func() bool {
+		return request() == "7-2"
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestMiddlewareV4ShouldServeStaleIfError(t *testing.T) {
+	calls := 0
+	conf := &Config{
+		Enabled: true,
+		Rules: []Rule{
+			{Path: "/stock/:id", TTL: "10ms", StaleIfError: "1m"},
+		},
+	}
+	server := echo4.New()
+	server.Use(MiddlewareV4(conf, newMemoryCache()))
+	server.GET("/stock/:id", func(ctx echo4.Context) error {
+		calls++
+		switch calls {
+		case 1:
+			return ctx.String(http.StatusOK, "in stock")
+		case 2:
+			return errors.New("upstream is down")
+		default:
+			return ctx.String(http.StatusBadGateway, "bad gateway")
+		}
+	})
+
+	for call := 1; call <= 3; call++ {
+		req := httptest.NewRequest(http.MethodGet, "/stock/7", nil)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "in stock", rec.Body.String())
+		time.Sleep(20 * time.Millisecond)
+	}
+	assert.Equal(t, 3, calls)
+}
+
+func TestMiddlewareV4ShouldReplaceStaleOnSuccessfulResponse(t *testing.T) {
+	calls := 0
+	conf := &Config{
+		Enabled: true,
+		Rules: []Rule{
+			{Path: "/stock/:id", TTL: "10ms", StaleIfError: "1m"},
+		},
+	}
+	server := echo4.New()
+	server.Use(MiddlewareV4(conf, newMemoryCache()))
+	server.GET("/stock/:id", func(ctx echo4.Context) error {
+		calls++
+		return ctx.String(http.StatusOK, fmt.Sprintf("call %d", calls))
+	})
+
+	request := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/stock/7", nil)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, "call 1", request().Body.String())
+	time.Sleep(20 * time.Millisecond)
+	rec := request()
+	assert.Equal(t, "call 2", rec.Body.String())
+	assert.Equal(t, "text/plain; charset=UTF-8", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "call 2", request().Body.String())
+}
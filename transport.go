@@ -0,0 +1,283 @@
+package cacheman
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Transport is http.RoundTripper caching GET responses of another transport, e.g. for http.Client calling slow APIs.
+// Cached response carries X-Cache header: HIT, MISS, BYPASS or REVALIDATED.
+type Transport struct {
+	// Transport is underlying transport. Default is http.DefaultTransport.
+	Transport http.RoundTripper
+	// Manager stores responses with its namespace, codec, metrics and logger, and can purge them
+	Manager *Manager
+}
+
+// NewTransport creates a caching transport over transport, nil transport means http.DefaultTransport.
+// Responses are keyed by full URL and VaryHeaders of config, e.g. Authorization.
+// Freshness is taken from Cache-Control max-age or Expires of response, then TTL of config.
+func NewTransport(config *Config, cache CacheInterface, transport http.RoundTripper) *Transport {
+	return &Transport{
+		Transport: transport,
+		Manager:   NewCacheManager(config, cache),
+	}
+}
+
+// RoundTrip serves GET request from cache, revalidates stale content with If-None-Match,
+// or sends request through underlying transport and stores its response
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c := t.Manager
+	// Request with its own validators or range expects response of server as is
+	if !c.Enabled || req.Method != http.MethodGet || req.Header.Get("Range") != "" ||
+		req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return t.transport().RoundTrip(req)
+	}
+	route := req.URL.Host
+	cacheKey := c.transportKey(req)
+	if !c.Policy.CanLookup(req) {
+		c.count(MetricBypasses, route)
+		return t.fetch(req, cacheKey, route, CacheBypass)
+	}
+
+	content, found := c.lookupTransport(cacheKey, route)
+	now := time.Now()
+	if found && content.Fresh(now) {
+		c.countContent(MetricHits, MetricNegativeHits, content, route)
+		return c.cachedResponse(req, content, cacheKey, CacheHit), nil
+	}
+	c.count(MetricMisses, route)
+	if !found || content.ETag == "" {
+		return t.fetch(req, cacheKey, route, CacheMiss)
+	}
+
+	c.Logger.Debug("Cache revalidates", "key", cacheKey, "route", route)
+	conditional := req.Clone(req.Context())
+	conditional.Header.Set("If-None-Match", content.ETag)
+	resp, e := t.transport().RoundTrip(conditional)
+	if e != nil {
+		return nil, e
+	}
+	if resp.StatusCode != http.StatusNotModified {
+		return c.storeTransport(req, resp, cacheKey, route, CacheMiss), nil
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	// Headers of 304 update headers of cached content, e.g. new Cache-Control or Date
+	if content.Headers == nil {
+		content.Headers = http.Header{}
+	}
+	for name, values := range resp.Header {
+		content.Headers[name] = values
+	}
+	ttl, storable := transportTTL(content.Headers, c.TTL, now)
+	if storable {
+		content.StoredAt = now.UnixNano()
+		content.FreshUntil = now.Add(ttl).UnixNano()
+		c.setTransport(cacheKey, content, ttl, route)
+	}
+	return c.cachedResponse(req, content, cacheKey, CacheRevalidated), nil
+}
+
+func (t *Transport) transport() http.RoundTripper {
+	if t.Transport != nil {
+		return t.Transport
+	}
+	return http.DefaultTransport
+}
+
+// fetch sends request through underlying transport and stores its response
+func (t *Transport) fetch(req *http.Request, cacheKey, route, status string) (*http.Response, error) {
+	resp, e := t.transport().RoundTrip(req)
+	if e != nil {
+		return nil, e
+	}
+	return t.Manager.storeTransport(req, resp, cacheKey, route, status), nil
+}
+
+// transportKey returns cache key of outbound request: full URL, and hash of VaryHeaders of request if any is present
+func (c *Manager) transportKey(req *http.Request) string {
+	key := req.URL.String()
+	values := []string{}
+	for _, name := range c.VaryHeaders {
+		if value := strings.Join(req.Header.Values(name), ","); value != "" {
+			values = append(values, name+": "+value)
+		}
+	}
+	if len(values) > 0 {
+		key = fmt.Sprintf("%s#headers=%x", key, sha256.Sum256([]byte(strings.Join(values, "\n"))))
+	}
+	return key
+}
+
+// lookupTransport gets cached content of outbound request, fresh or not
+func (c *Manager) lookupTransport(cacheKey, route string) (*Content, bool) {
+	b, found := c.get(cacheKey, route)
+	if !found {
+		return nil, false
+	}
+	var content Content
+	if e := c.Codec.Decode(b, &content); e != nil {
+		c.Logger.Warn("Cache fails to decode", "key", cacheKey, "route", route, "error", e)
+		c.count(MetricDecodeErrors, route)
+		return nil, false
+	}
+	return &content, true
+}
+
+// storeTransport stores response if it is cacheable and returns it with X-Cache header.
+// Body of response is read into memory, unless it is larger than MaxBodySize.
+func (c *Manager) storeTransport(req *http.Request, resp *http.Response, cacheKey, route, status string) *http.Response {
+	c.writeCacheStatus(resp.Header, status, cacheKey, nil)
+	now := time.Now()
+	ttl, storable := transportTTL(resp.Header, c.TTL, now)
+	if _, cacheable := c.statusTTL(resp.StatusCode, ttl); !cacheable || !storable {
+		return resp
+	}
+	if ttl <= 0 && resp.Header.Get("ETag") == "" {
+		return resp
+	}
+	body, e := ioutil.ReadAll(limitReader(resp.Body, c.MaxBodySize))
+	if e != nil {
+		c.Logger.Warn("Cache fails to read response", "key", cacheKey, "route", route, "error", e)
+		resp.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(body), resp.Body), Closer: resp.Body}
+		return resp
+	}
+	if c.MaxBodySize > 0 && len(body) > c.MaxBodySize {
+		resp.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(body), resp.Body), Closer: resp.Body}
+		return resp
+	}
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	header := resp.Header.Clone()
+	for _, name := range cacheStatusHeaders {
+		header.Del(name)
+	}
+	content := &Content{
+		Status:       resp.StatusCode,
+		Headers:      header,
+		Content:      body,
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+		StoredAt:     now.UnixNano(),
+		FreshUntil:   now.Add(ttl).UnixNano(),
+		Negative:     negative(resp.StatusCode),
+	}
+	c.setTransport(cacheKey, content, ttl, route)
+	return resp
+}
+
+// setTransport stores content of outbound request.
+// Content with ETag is kept for TTL of config after it turns stale, so it can be revalidated.
+func (c *Manager) setTransport(cacheKey string, content *Content, ttl time.Duration, route string) {
+	b, e := c.Codec.Encode(content)
+	if e != nil {
+		c.Logger.Error("Cache fails to encode", "key", cacheKey, "route", route, "error", e)
+		c.count(MetricStoreFailures, route)
+		return
+	}
+	storeTTL := ttl
+	if content.ETag != "" {
+		storeTTL += c.TTL
+	}
+	if e := c.setWithTTL(cacheKey, b, storeTTL, route); e != nil {
+		c.count(MetricStoreFailures, route)
+		return
+	}
+	c.countContent(MetricStores, MetricNegativeStores, content, route)
+}
+
+// cachedResponse creates response of request from cached content
+func (c *Manager) cachedResponse(req *http.Request, content *Content, cacheKey, status string) *http.Response {
+	header := content.Headers.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	c.writeCacheStatus(header, status, cacheKey, content)
+	header.Set("Content-Length", strconv.Itoa(len(content.Content)))
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", content.Status, http.StatusText(content.Status)),
+		StatusCode:    content.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(content.Content)),
+		ContentLength: int64(len(content.Content)),
+		Request:       req,
+	}
+}
+
+// transportTTL returns freshness of response from Cache-Control max-age, then Expires, then given TTL.
+// Age header of response, e.g. from a shared cache upstream, is subtracted from freshness of max-age and Expires.
+// Response with Cache-Control: no-store is not stored, no-cache is stored but revalidated on every use.
+func transportTTL(header http.Header, ttl time.Duration, now time.Time) (time.Duration, bool) {
+	directives := parseCacheControl(header)
+	if _, ok := directives["no-store"]; ok {
+		return 0, false
+	}
+	if _, ok := directives["no-cache"]; ok {
+		return 0, true
+	}
+	if value, ok := directives["max-age"]; ok {
+		seconds, e := strconv.Atoi(value)
+		if e != nil || seconds < 0 {
+			return 0, true
+		}
+		return remainingFreshness(time.Duration(seconds)*time.Second, header), true
+	}
+	if expires := header.Get("Expires"); expires != "" {
+		expiresAt, e := http.ParseTime(expires)
+		if e != nil {
+			// Invalid Expires, e.g. 0, means already expired
+			return 0, true
+		}
+		date, e := http.ParseTime(header.Get("Date"))
+		if e != nil {
+			// Without Date, Expires is compared to local clock which already includes age
+			if expiresAt.Before(now) {
+				return 0, true
+			}
+			return expiresAt.Sub(now), true
+		}
+		if expiresAt.Before(date) {
+			return 0, true
+		}
+		return remainingFreshness(expiresAt.Sub(date), header), true
+	}
+	return ttl, true
+}
+
+// remainingFreshness returns freshness lifetime of response less its Age header, or zero if it is already stale
+func remainingFreshness(lifetime time.Duration, header http.Header) time.Duration {
+	age, e := strconv.Atoi(strings.TrimSpace(header.Get("Age")))
+	if e != nil || age < 0 {
+		return lifetime
+	}
+	if remaining := lifetime - time.Duration(age)*time.Second; remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// limitReader reads up to one byte beyond max, so body larger than max can be detected. Zero max means no limit.
+func limitReader(reader io.Reader, max int) io.Reader {
+	if max <= 0 {
+		return reader
+	}
+	return io.LimitReader(reader, int64(max)+1)
+}
+
+// readCloser reads from Reader and closes Closer
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,186 @@
+package cacheman
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newPartnerAPI(handler func(writer http.ResponseWriter, req *http.Request, call int32)) (*httptest.Server, *int32) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		handler(writer, req, atomic.AddInt32(&calls, 1))
+	}))
+	return server, &calls
+}
+
+func clientGet(t *testing.T, client *http.Client, url string, header map[string]string) (*http.Response, string) {
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	resp, e := client.Do(req)
+	assert.NoError(t, e)
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	return resp, string(body)
+}
+
+func TestTransportShouldServeFreshResponseFromCache(t *testing.T) {
+	server, calls := newPartnerAPI(func(writer http.ResponseWriter, req *http.Request, call int32) {
+		writer.Header().Set("Cache-Control", "max-age=60")
+		fmt.Fprintf(writer, "rate-%d", call)
+	})
+	defer server.Close()
+	client := &http.Client{Transport: NewTransport(&Config{Enabled: true, TTL: "1m"}, newMemoryCache(), nil)}
+
+	resp, body := clientGet(t, client, server.URL+"/rates?currency=THB", nil)
+	assert.Equal(t, "rate-1", body)
+	assert.Equal(t, CacheMiss, resp.Header.Get("X-Cache"))
+
+	resp, body = clientGet(t, client, server.URL+"/rates?currency=THB", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "rate-1", body)
+	assert.Equal(t, CacheHit, resp.Header.Get("X-Cache"))
+	assert.Equal(t, "0", resp.Header.Get("Age"))
+
+	_, body = clientGet(t, client, server.URL+"/rates?currency=USD", nil)
+	assert.Equal(t, "rate-2", body)
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+}
+
+func TestTransportShouldKeyBySelectedHeaders(t *testing.T) {
+	server, _ := newPartnerAPI(func(writer http.ResponseWriter, req *http.Request, call int32) {
+		fmt.Fprintf(writer, "%s-%d", req.Header.Get("Authorization"), call)
+	})
+	defer server.Close()
+	client := &http.Client{Transport: NewTransport(&Config{
+		Enabled:     true,
+		TTL:         "1m",
+		VaryHeaders: []string{"Authorization"},
+	}, newMemoryCache(), nil)}
+
+	_, body := clientGet(t, client, server.URL, map[string]string{"Authorization": "alice"})
+	assert.Equal(t, "alice-1", body)
+	_, body = clientGet(t, client, server.URL, map[string]string{"Authorization": "bob"})
+	assert.Equal(t, "bob-2", body)
+	_, body = clientGet(t, client, server.URL, map[string]string{"Authorization": "alice"})
+	assert.Equal(t, "alice-1", body)
+}
+
+func TestTransportShouldNotStoreNoStoreResponse(t *testing.T) {
+	server, calls := newPartnerAPI(func(writer http.ResponseWriter, req *http.Request, call int32) {
+		writer.Header().Set("Cache-Control", "no-store")
+		fmt.Fprint(writer, "secret")
+	})
+	defer server.Close()
+	client := &http.Client{Transport: NewTransport(&Config{Enabled: true, TTL: "1m"}, newMemoryCache(), nil)}
+
+	clientGet(t, client, server.URL, nil)
+	resp, _ := clientGet(t, client, server.URL, nil)
+
+	assert.Equal(t, CacheMiss, resp.Header.Get("X-Cache"))
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+}
+
+func TestTransportShouldRevalidateStaleResponseWithETag(t *testing.T) {
+	server, calls := newPartnerAPI(func(writer http.ResponseWriter, req *http.Request, call int32) {
+		writer.Header().Set("ETag", `"v1"`)
+		writer.Header().Set("Cache-Control", "no-cache")
+		if req.Header.Get("If-None-Match") == `"v1"` {
+			writer.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprintf(writer, "catalog-%d", call)
+	})
+	defer server.Close()
+	client := &http.Client{Transport: NewTransport(&Config{Enabled: true, TTL: "1m"}, newMemoryCache(), nil)}
+
+	_, body := clientGet(t, client, server.URL, nil)
+	assert.Equal(t, "catalog-1", body)
+
+	resp, body := clientGet(t, client, server.URL, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "catalog-1", body)
+	assert.Equal(t, CacheRevalidated, resp.Header.Get("X-Cache"))
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+}
+
+func TestTransportShouldPassRequestWithOwnValidatorThrough(t *testing.T) {
+	server, _ := newPartnerAPI(func(writer http.ResponseWriter, req *http.Request, call int32) {
+		writer.Header().Set("ETag", `"v1"`)
+		if req.Header.Get("If-None-Match") == `"v1"` {
+			writer.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprint(writer, "catalog")
+	})
+	defer server.Close()
+	client := &http.Client{Transport: NewTransport(&Config{Enabled: true, TTL: "1m"}, newMemoryCache(), nil)}
+	clientGet(t, client, server.URL, nil)
+
+	resp, _ := clientGet(t, client, server.URL, map[string]string{"If-None-Match": `"v1"`})
+
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("X-Cache"))
+}
+
+func TestTransportTTL(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	header := func(values map[string]string) http.Header {
+		output := http.Header{}
+		for name, value := range values {
+			output.Set(name, value)
+		}
+		return output
+	}
+
+	ttl, storable := transportTTL(header(map[string]string{"Cache-Control": "public, max-age=30"}), time.Minute, now)
+	assert.True(t, storable)
+	assert.Equal(t, 30*time.Second, ttl)
+
+	ttl, storable = transportTTL(header(map[string]string{
+		"Date":    now.Format(http.TimeFormat),
+		"Expires": now.Add(time.Hour).Format(http.TimeFormat),
+	}), time.Minute, now)
+	assert.True(t, storable)
+	assert.Equal(t, time.Hour, ttl)
+
+	ttl, storable = transportTTL(header(map[string]string{"Expires": "0"}), time.Minute, now)
+	assert.True(t, storable)
+	assert.Equal(t, time.Duration(0), ttl)
+
+	ttl, _ = transportTTL(http.Header{}, time.Minute, now)
+	assert.Equal(t, time.Minute, ttl)
+
+	_, storable = transportTTL(header(map[string]string{"Cache-Control": "no-store"}), time.Minute, now)
+	assert.False(t, storable)
+}
+
+func TestTransportTTLShouldSubtractAge(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	header := http.Header{}
+	header.Set("Cache-Control", "max-age=60")
+	header.Set("Age", "20")
+
+	ttl, storable := transportTTL(header, time.Minute, now)
+	assert.True(t, storable)
+	assert.Equal(t, 40*time.Second, ttl)
+
+	header.Set("Age", "90")
+	ttl, _ = transportTTL(header, time.Minute, now)
+	assert.Equal(t, time.Duration(0), ttl)
+
+	header = http.Header{}
+	header.Set("Date", now.Format(http.TimeFormat))
+	header.Set("Expires", now.Add(time.Hour).Format(http.TimeFormat))
+	header.Set("Age", "600")
+	ttl, _ = transportTTL(header, time.Minute, now)
+	assert.Equal(t, 50*time.Minute, ttl)
+}
@@ -0,0 +1,100 @@
+package cacheman
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// varyIndexKey returns key of vary index which keeps header names response of key varies by
+func varyIndexKey(key string) string {
+	return key + "#vary"
+}
+
+// variantKey returns key of response variant selected by values of given request headers
+func variantKey(key string, names []string, req *http.Request) string {
+	if len(names) == 0 {
+		return key
+	}
+	hash := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(hash, "%s=%s\n", name, strings.Join(req.Header.Values(name), ","))
+	}
+	return fmt.Sprintf("%s#vary=%x", key, hash.Sum(nil)[:8])
+}
+
+// parseVary returns header names in Vary header, and true if response varies by everything (*)
+func parseVary(header http.Header) ([]string, bool) {
+	names := []string{}
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" {
+				return nil, true
+			}
+			if name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return mergeVaryHeaders(names), false
+}
+
+// mergeVaryHeaders merges lists of header names into a sorted list of unique canonical names
+func mergeVaryHeaders(lists ...[]string) []string {
+	seen := map[string]bool{}
+	output := []string{}
+	for _, names := range lists {
+		for _, name := range names {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			output = append(output, name)
+		}
+	}
+	sort.Strings(output)
+	return output
+}
+
+// lookupKey returns key of cached variant matching request
+func (c *Manager) lookupKey(key string, req *http.Request) string {
+	names := c.VaryHeaders
+	if index, e := c.Cache.Get(c.createKey(varyIndexKey(key))); e == nil && len(index) > 0 {
+		names = mergeVaryHeaders(names, strings.Split(string(index), ","))
+	}
+	return variantKey(key, names, req)
+}
+
+// variant is key of stored response variant, and names of request headers it is selected by
+type variant struct {
+	key   string
+	names []string
+}
+
+// matches returns true if request of key selects the variant
+func (v variant) matches(key string, req *http.Request) bool {
+	return variantKey(key, v.names, req) == v.key
+}
+
+// storeKey returns variant to store response as and records Vary header of response into vary index.
+// Vary index is deleted if response does not vary, so lookups stop selecting old variants.
+// It returns false if response must not be stored because it varies by everything.
+func (c *Manager) storeKey(key string, req *http.Request, header http.Header, ttl time.Duration) (variant, bool) {
+	names, varyAll := parseVary(header)
+	if varyAll {
+		return variant{}, false
+	}
+	if len(names) > 0 {
+		c.SetWithTTL(varyIndexKey(key), []byte(strings.Join(names, ",")), ttl)
+	} else {
+		// Index may not exist, so error of deleting it is not an error of storing
+		c.Cache.Delete(c.createKey(varyIndexKey(key)))
+	}
+	names = mergeVaryHeaders(c.VaryHeaders, names)
+	return variant{key: variantKey(key, names, req), names: names}, true
+}
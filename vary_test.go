@@ -0,0 +1,147 @@
+package cacheman
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	echo4 "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVaryShouldCanonicalizeAndSortNames(t *testing.T) {
+	header := http.Header{}
+	header.Add("Vary", "accept-language, Accept-Encoding")
+	header.Add("Vary", "Accept-Language")
+
+	names, varyAll := parseVary(header)
+
+	assert.False(t, varyAll)
+	assert.Equal(t, []string{"Accept-Encoding", "Accept-Language"}, names)
+}
+
+func TestParseVaryWithAsterisk(t *testing.T) {
+	header := http.Header{}
+	header.Set("Vary", "Accept-Language, *")
+
+	_, varyAll := parseVary(header)
+
+	assert.True(t, varyAll)
+}
+
+func TestVariantKeyWithoutNamesShouldBeKey(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	assert.Equal(t, "/products", variantKey("/products", nil, req))
+}
+
+func TestVariantKeyShouldDifferByHeaderValue(t *testing.T) {
+	en := httptest.NewRequest(http.MethodGet, "/", nil)
+	en.Header.Set("Accept-Language", "en")
+	fr := httptest.NewRequest(http.MethodGet, "/", nil)
+	fr.Header.Set("Accept-Language", "fr")
+	names := []string{"Accept-Language"}
+
+	assert.NotEqual(t, variantKey("/products", names, en), variantKey("/products", names, fr))
+	assert.Equal(t, variantKey("/products", names, en), variantKey("/products", names, en.Clone(en.Context())))
+}
+
+func TestMiddlewareV4ShouldCacheVariantsByResponseVary(t *testing.T) {
+	calls := 0
+	conf := &Config{
+		Enabled: true,
+		TTL:     "1m",
+		Paths:   []string{"/greeting"},
+	}
+	server := echo4.New()
+	server.Use(MiddlewareV4(conf, newMemoryCache()))
+	server.GET("/greeting", func(ctx echo4.Context) error {
+		calls++
+		ctx.Response().Header().Set("Vary", "Accept-Language")
+		if ctx.Request().Header.Get("Accept-Language") == "fr" {
+			return ctx.String(http.StatusOK, "bonjour")
+		}
+		return ctx.String(http.StatusOK, "hello")
+	})
+
+	request := func(language string) string {
+		req := httptest.NewRequest(http.MethodGet, "/greeting", nil)
+		req.Header.Set("Accept-Language", language)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+
+	assert.Equal(t, "hello", request("en"))
+	assert.Equal(t, "bonjour", request("fr"))
+	assert.Equal(t, "hello", request("en"))
+	assert.Equal(t, "bonjour", request("fr"))
+	assert.Equal(t, 2, calls)
+}
+
+func TestMiddlewareV4ShouldCacheVariantsByConfiguredHeaders(t *testing.T) {
+	calls := 0
+	conf := &Config{
+		Enabled:     true,
+		TTL:         "1m",
+		Paths:       []string{"/me"},
+		VaryHeaders: []string{"authorization"},
+	}
+	server := echo4.New()
+	server.Use(MiddlewareV4(conf, newMemoryCache()))
+	server.GET("/me", func(ctx echo4.Context) error {
+		calls++
+		return ctx.String(http.StatusOK, ctx.Request().Header.Get("Authorization"))
+	})
+
+	request := func(token string) string {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("Authorization", token)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+
+	assert.Equal(t, "alice", request("alice"))
+	assert.Equal(t, "bob", request("bob"))
+	assert.Equal(t, "alice", request("alice"))
+	assert.Equal(t, 2, calls)
+}
+
+func TestMiddlewareV4ShouldServeCachedContentAfterRouteStopsVarying(t *testing.T) {
+	calls := 0
+	varies := true
+	conf := &Config{
+		Enabled: true,
+		TTL:     "1m",
+		Paths:   []string{"/greeting"},
+		Policy:  PolicyRespectHeaders,
+	}
+	server := echo4.New()
+	server.Use(MiddlewareV4(conf, newMemoryCache()))
+	server.GET("/greeting", func(ctx echo4.Context) error {
+		calls++
+		if varies {
+			ctx.Response().Header().Set("Vary", "Accept-Language")
+			return ctx.String(http.StatusOK, "hello")
+		}
+		return ctx.String(http.StatusOK, "hi")
+	})
+
+	request := func(cacheControl string) string {
+		req := httptest.NewRequest(http.MethodGet, "/greeting", nil)
+		req.Header.Set("Accept-Language", "en")
+		req.Header.Set("Cache-Control", cacheControl)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+
+	assert.Equal(t, "hello", request(""))
+	varies = false
+	// no-cache skips cached variant, but its response replaces it
+	assert.Equal(t, "hi", request("no-cache"))
+	assert.Equal(t, "hi", request(""))
+	assert.Equal(t, "hi", request(""))
+	assert.Equal(t, 2, calls)
+}